@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bit2swaz/orion/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var deployPort int
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote <service>",
+	Short: "Promote a service's canaries and roll out the rest of the replicas",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		svc, err := postServiceAction(args[0], "promote")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Service %s: version %d promoted\n", svc.Name, svc.Version)
+	},
+}
+
+var abortCmd = &cobra.Command{
+	Use:   "abort <service>",
+	Short: "Abort a service update and return to the previous revision",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		svc, err := postServiceAction(args[0], "abort")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Service %s rolling back as version %d (image %s)\n", svc.Name, svc.Version, svc.Template.Image)
+	},
+}
+
+func postServiceAction(name, action string) (*service.Service, error) {
+	url := fmt.Sprintf("http://localhost:%d/services/%s/%s", deployPort, name, action)
+	resp, err := http.Post(url, "application/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API returned status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var svc service.Service
+	if err := json.NewDecoder(resp.Body).Decode(&svc); err != nil {
+		return nil, fmt.Errorf("decoding response: %v", err)
+	}
+	return &svc, nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{promoteCmd, abortCmd} {
+		cmd.Flags().IntVar(&deployPort, "port", 8080, "API server port")
+		rootCmd.AddCommand(cmd)
+	}
+}
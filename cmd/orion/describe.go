@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/spf13/cobra"
+)
+
+var describePort int
+
+var describeCmd = &cobra.Command{
+	Use:   "describe <task-id>",
+	Short: "Show a task, its history and why it is not scheduled yet",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		url := fmt.Sprintf("http://localhost:%d/tasks/%s", describePort, args[0])
+		resp, err := http.Get(url)
+		if err != nil {
+			fmt.Printf("Error connecting to API: %v\n", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			fmt.Printf("Error: API returned status %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+			return
+		}
+
+		var t task.Task
+		if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+			fmt.Printf("Error decoding response: %v\n", err)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintf(w, "ID:\t%s\n", t.ID)
+		fmt.Fprintf(w, "Name:\t%s\n", t.Name)
+		fmt.Fprintf(w, "Image:\t%s\n", t.Image)
+		fmt.Fprintf(w, "State:\t%s\n", t.State)
+		fmt.Fprintf(w, "Node:\t%s\n", t.NodeID)
+		fmt.Fprintf(w, "Memory:\t%d\n", t.Memory)
+		fmt.Fprintf(w, "CPU:\t%.2f\n", t.Cpu)
+		if t.Exit != nil {
+			fmt.Fprintf(w, "Exit:\t%s (code %d)\n", t.Exit.Reason, t.Exit.Code)
+		}
+		if t.Health != nil {
+			fmt.Fprintf(w, "Health:\t%s: %s (%s)\n", t.Health.Status, t.Health.Message, t.Health.Time.Format("2006-01-02 15:04:05"))
+		} else if t.HealthCheck != nil {
+			fmt.Fprintf(w, "Health:\tstarting\n")
+		}
+		if t.Drift != nil {
+			fmt.Fprintf(w, "Drift:\t%s (%s)\n", t.Drift.Message, t.Drift.Time.Format("2006-01-02 15:04:05"))
+		}
+		w.Flush()
+
+		if len(t.History) > 0 {
+			fmt.Println("\nHistory:")
+			w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+			fmt.Fprintln(w, "Time\tState\tNode\tMessage")
+			for _, h := range t.History {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.Time.Format("2006-01-02 15:04:05"), h.State, h.NodeID, h.Message)
+			}
+			w.Flush()
+		}
+
+		if (t.State != task.Pending && t.State != task.Lost) || t.Unschedulable == nil {
+			return
+		}
+
+		fmt.Printf("\nUnschedulable as of %s: %s\n\n", t.Unschedulable.Time.Format("2006-01-02 15:04:05"), t.Unschedulable.Message)
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "Node\tPlugin\tReason")
+		for _, r := range t.Unschedulable.Rejections {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", r.NodeID, r.Plugin, r.Reason)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	describeCmd.Flags().IntVar(&describePort, "port", 8080, "API server port")
+	rootCmd.AddCommand(describeCmd)
+}
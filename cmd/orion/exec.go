@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/api"
+	"github.com/bit2swaz/orion/internal/worker"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/moby/term"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execPort  int
+	execStdin bool
+	execTty   bool
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <task-id> -- <command> [args...]",
+	Short: "Run a command inside a task's container, wherever it runs",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(runExec(args[0], args[1:]))
+	},
+}
+
+// runExec runs command in the task's container and returns its exit code.
+func runExec(taskID string, command []string) int {
+	inFd, isTerminal := term.GetFdInfo(os.Stdin)
+	if execTty && !isTerminal {
+		fmt.Fprintln(os.Stderr, "Error: --tty needs stdin to be a terminal")
+		return 1
+	}
+
+	q := url.Values{"cmd": command}
+	if execStdin {
+		q.Set("stdin", "true")
+	}
+	if execTty {
+		q.Set("tty", "true")
+		if ws, err := term.GetWinsize(inFd); err == nil {
+			q.Set("h", strconv.Itoa(int(ws.Height)))
+			q.Set("w", strconv.Itoa(int(ws.Width)))
+		}
+	}
+
+	addr := fmt.Sprintf("localhost:%d", execPort)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to API: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s/tasks/%s/exec?%s", addr, taskID, q.Encode()), nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	if err := req.Write(conn); err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending exec request: %v\n", err)
+		return 1
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading exec response: %v\n", err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Error: API returned status %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+	execID := resp.Header.Get(api.ExecIDHeader)
+
+	if execTty {
+		state, err := term.SetRawTerminal(inFd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting terminal to raw mode: %v\n", err)
+			return 1
+		}
+		stop := watchResize(inFd, func(ws *term.Winsize) {
+			resizeExec(taskID, execID, ws)
+		})
+		defer func() {
+			stop()
+			term.RestoreTerminal(inFd, state)
+		}()
+	}
+
+	if execStdin {
+		go func() {
+			io.Copy(conn, os.Stdin)
+			if c, ok := conn.(*net.TCPConn); ok {
+				c.CloseWrite()
+			}
+		}()
+	}
+
+	if execTty {
+		io.Copy(os.Stdout, br)
+	} else {
+		stdcopy.StdCopy(os.Stdout, os.Stderr, br)
+	}
+
+	return execExitCode(taskID, execID)
+}
+
+func resizeExec(taskID, execID string, ws *term.Winsize) {
+	u := fmt.Sprintf("http://localhost:%d/tasks/%s/exec/%s/resize?h=%d&w=%d", execPort, taskID, execID, ws.Height, ws.Width)
+	resp, err := http.Post(u, "", nil)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// execExitCode waits briefly for the exec to be reported finished, as the
+// stream can close just before Docker records the exit code.
+func execExitCode(taskID, execID string) int {
+	u := fmt.Sprintf("http://localhost:%d/tasks/%s/exec/%s", execPort, taskID, execID)
+	for i := 0; i < 10; i++ {
+		resp, err := http.Get(u)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading exit code: %v\n", err)
+			return 1
+		}
+
+		var info worker.ExecInfo
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading exit code: API returned status %s\n", resp.Status)
+			return 1
+		}
+		if !info.Running {
+			return info.ExitCode
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return 1
+}
+
+func init() {
+	execCmd.Flags().IntVar(&execPort, "port", 8080, "API server port")
+	execCmd.Flags().BoolVarP(&execStdin, "interactive", "i", false, "Pass stdin to the command")
+	execCmd.Flags().BoolVarP(&execTty, "tty", "t", false, "Run the command on a terminal")
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	logsPort       int
+	logsFollow     bool
+	logsTail       string
+	logsSince      string
+	logsTimestamps bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <task-id>",
+	Short: "Print the output of a task's container, wherever it runs",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		q := url.Values{}
+		if logsFollow {
+			q.Set("follow", "true")
+		}
+		if logsTail != "" {
+			q.Set("tail", logsTail)
+		}
+		if logsSince != "" {
+			q.Set("since", logsSince)
+		}
+		if logsTimestamps {
+			q.Set("timestamps", "true")
+		}
+
+		u := fmt.Sprintf("http://localhost:%d/tasks/%s/logs?%s", logsPort, args[0], q.Encode())
+		resp, err := http.Get(u)
+		if err != nil {
+			fmt.Printf("Error connecting to API: %v\n", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			fmt.Printf("Error: API returned status %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+			return
+		}
+
+		if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading logs: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	logsCmd.Flags().IntVar(&logsPort, "port", 8080, "API server port")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep streaming new output")
+	logsCmd.Flags().StringVar(&logsTail, "tail", "", "Number of lines to show from the end (default: all)")
+	logsCmd.Flags().StringVar(&logsSince, "since", "", "Only show output since a timestamp (RFC 3339) or a relative time like 10m")
+	logsCmd.Flags().BoolVarP(&logsTimestamps, "timestamps", "t", false, "Prefix each line with its timestamp")
+	rootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/moby/term"
+)
+
+// watchResize calls resize with the terminal size every time it changes,
+// until the returned stop function is called.
+func watchResize(fd uintptr, resize func(*term.Winsize)) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigs:
+				if ws, err := term.GetWinsize(fd); err == nil {
+					resize(ws)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "github.com/moby/term"
+
+// watchResize is a no-op: Windows consoles have no SIGWINCH, so the size
+// stays the one the exec started with.
+func watchResize(fd uintptr, resize func(*term.Winsize)) (stop func()) {
+	return func() {}
+}
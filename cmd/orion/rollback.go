@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bit2swaz/orion/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackPort      int
+	rollbackToVersion int
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <service>",
+	Short: "Roll a service back to an earlier template revision",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		url := fmt.Sprintf("http://localhost:%d/services/%s/rollback", rollbackPort, args[0])
+		if rollbackToVersion > 0 {
+			url = fmt.Sprintf("%s?to=%d", url, rollbackToVersion)
+		}
+		resp, err := http.Post(url, "application/json", nil)
+		if err != nil {
+			fmt.Printf("Error connecting to API: %v\n", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			fmt.Printf("Error: API returned status %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+			return
+		}
+
+		var svc service.Service
+		if err := json.NewDecoder(resp.Body).Decode(&svc); err != nil {
+			fmt.Printf("Error decoding response: %v\n", err)
+			return
+		}
+		fmt.Printf("Service %s rolling out version %d (image %s)\n", svc.Name, svc.Version, svc.Template.Image)
+	},
+}
+
+func init() {
+	rollbackCmd.Flags().IntVar(&rollbackPort, "port", 8080, "API server port")
+	rollbackCmd.Flags().IntVar(&rollbackToVersion, "to-version", 0, "Revision to roll back to (default: the previous one)")
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -2,20 +2,19 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net"
-	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/bit2swaz/orion/internal/api"
 	"github.com/bit2swaz/orion/internal/cluster"
 	"github.com/bit2swaz/orion/internal/manager"
 	"github.com/bit2swaz/orion/internal/scheduler"
+	"github.com/bit2swaz/orion/internal/secret"
 	"github.com/bit2swaz/orion/internal/store"
-	"github.com/bit2swaz/orion/internal/task"
 	"github.com/bit2swaz/orion/internal/worker"
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -26,6 +25,12 @@ var (
 	nodeID     string
 	joinAddr   string
 	bootstrap  bool
+
+	nodeGracePeriod time.Duration
+	resyncInterval  time.Duration
+	execConcurrency int
+	execTimeout     time.Duration
+	secretsKeyFile  string
 )
 
 func getLocalIP() string {
@@ -38,9 +43,25 @@ func getLocalIP() string {
 	return localAddr.IP.String()
 }
 
+// checkFlags rejects tuning flags the manager can't run with.
+func checkFlags(cmd *cobra.Command, args []string) error {
+	switch {
+	case resyncInterval <= 0:
+		return fmt.Errorf("--resync-interval must be positive, got %s", resyncInterval)
+	case execTimeout <= 0:
+		return fmt.Errorf("--exec-timeout must be positive, got %s", execTimeout)
+	case execConcurrency <= 0:
+		return fmt.Errorf("--exec-concurrency must be at least 1, got %d", execConcurrency)
+	case nodeGracePeriod < 0:
+		return fmt.Errorf("--node-grace-period must not be negative, got %s", nodeGracePeriod)
+	}
+	return nil
+}
+
 var rootCmd = &cobra.Command{
-	Use:   "orion",
-	Short: "Orion is a distributed task scheduler",
+	Use:     "orion",
+	Short:   "Orion is a distributed task scheduler",
+	PreRunE: checkFlags,
 	Run: func(cmd *cobra.Command, args []string) {
 		hostname, _ := os.Hostname()
 		if nodeID == "" {
@@ -62,7 +83,7 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		c, err := cluster.New(gossipPort, raftPort, nodeID, "manager", s)
+		c, err := cluster.New(gossipPort, raftPort, apiPort, nodeID, "manager", dataDir, s)
 		if err != nil {
 			fmt.Printf("Failed to create cluster: %v\n", err)
 			os.Exit(1)
@@ -74,8 +95,23 @@ var rootCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		var key *secret.Key
+		if secretsKeyFile != "" {
+			key, err = secret.LoadKey(secretsKeyFile)
+			if err != nil {
+				fmt.Printf("Failed to load cluster key: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		w.SecretsDir = filepath.Join(dataDir, "secrets")
+
 		sched := scheduler.New()
 		mgr := manager.New(s, sched, w, c, nodeID)
+		mgr.NodeGracePeriod = nodeGracePeriod
+		mgr.ResyncInterval = resyncInterval
+		mgr.ExecConcurrency = execConcurrency
+		mgr.ExecTimeout = execTimeout
+		mgr.SecretKey = key
 		go mgr.Run(context.Background())
 
 		if joinAddr != "" {
@@ -87,77 +123,13 @@ var rootCmd = &cobra.Command{
 			}
 		}
 
-		http.HandleFunc("/nodes", func(w http.ResponseWriter, r *http.Request) {
-			members := c.Members()
-			var nodes []map[string]interface{}
-			for _, m := range members {
-				node := map[string]interface{}{
-					"name":   m.Name,
-					"ip":     m.Addr.String(),
-					"role":   "worker",
-					"status": "alive",
-				}
-				nodes = append(nodes, node)
-			}
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(nodes)
-		})
-
-		http.HandleFunc("/raft", func(w http.ResponseWriter, r *http.Request) {
-			state := "Follower"
-			if s.IsLeader() {
-				state = "Leader"
-			}
-			tasks, _ := s.ListTasks()
-			resp := map[string]interface{}{
-				"state":     state,
-				"taskCount": len(tasks),
-				"tasks":     tasks,
-			}
-			json.NewEncoder(w).Encode(resp)
-		})
-
-		http.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
-			if r.Method != http.MethodPost {
-				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-				return
-			}
-
-			var t task.Task
-			if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-
-			t.ID = uuid.New()
-			t.State = task.Pending
-			t.StartTime = time.Now()
-
-			event := task.TaskEvent{
-				ID:        t.ID,
-				State:     task.Pending,
-				Timestamp: time.Now(),
-				Task:      t,
-			}
-
-			data, err := json.Marshal(event)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			if future := s.R.Apply(data, 10*time.Second); future.Error() != nil {
-				http.Error(w, future.Error().Error(), http.StatusInternalServerError)
-				return
-			}
-
-			w.WriteHeader(http.StatusCreated)
-			json.NewEncoder(w).Encode(t)
-		})
+		srv := api.New(s, c)
+		srv.Worker = w
+		srv.SecretKey = key
 
 		fmt.Printf("Starting API server on port %d\n", apiPort)
 		fmt.Printf("Gossip listening on port %d\n", gossipPort)
-		if err := http.ListenAndServe(fmt.Sprintf(":%d", apiPort), nil); err != nil {
+		if err := srv.ListenAndServe(fmt.Sprintf(":%d", apiPort)); err != nil {
 			fmt.Printf("Error starting API server: %v\n", err)
 			os.Exit(1)
 		}
@@ -171,6 +143,11 @@ func init() {
 	rootCmd.Flags().StringVar(&nodeID, "id", "", "Node ID")
 	rootCmd.Flags().StringVar(&joinAddr, "join", "", "Address of peer to join")
 	rootCmd.Flags().BoolVar(&bootstrap, "bootstrap", false, "Bootstrap the Raft cluster")
+	rootCmd.Flags().DurationVar(&nodeGracePeriod, "node-grace-period", manager.DefaultNodeGracePeriod, "How long a node may be missing before its tasks are rescheduled")
+	rootCmd.Flags().DurationVar(&resyncInterval, "resync-interval", manager.DefaultResyncInterval, "How often to reconcile everything in addition to reacting to changes")
+	rootCmd.Flags().IntVar(&execConcurrency, "exec-concurrency", manager.DefaultExecConcurrency, "How many containers this node starts or stops at once")
+	rootCmd.Flags().DurationVar(&execTimeout, "exec-timeout", manager.DefaultExecTimeout, "How long starting a container, including the image pull, may take")
+	rootCmd.Flags().StringVar(&secretsKeyFile, "secrets-key-file", "", "File with the base64 encoded 32 byte cluster key secrets are encrypted with (same on every node)")
 }
 
 func Execute() {
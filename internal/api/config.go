@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/bit2swaz/orion/internal/scheduler"
+)
+
+type SchedulerConfigResponse struct {
+	Config    scheduler.Config `json:"config"`
+	Available []string         `json:"available"`
+}
+
+func (s *Server) handleGetSchedulerConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := scheduler.DefaultConfig()
+	if raw, ok := s.Store.GetConfig(scheduler.ConfigKey); ok {
+		parsed, err := scheduler.ParseConfig(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cfg = parsed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SchedulerConfigResponse{
+		Config:    cfg,
+		Available: scheduler.Registered(),
+	})
+}
+
+func (s *Server) handlePutSchedulerConfig(w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := scheduler.ParseConfig(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := scheduler.NewWithConfig(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var compact bytes.Buffer
+	json.Compact(&compact, raw)
+	if err := s.Store.SetConfig(scheduler.ConfigKey, compact.Bytes()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SchedulerConfigResponse{
+		Config:    cfg,
+		Available: scheduler.Registered(),
+	})
+}
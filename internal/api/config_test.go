@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/scheduler"
+)
+
+func TestServer_SchedulerConfig(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	put := func(body string) int {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/config/scheduler", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	if code := put(`{"plugins":[{"name":"no_such_plugin"}]}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown plugin, got %d", code)
+	}
+	if _, ok := s.GetConfig(scheduler.ConfigKey); ok {
+		t.Fatalf("Invalid config was stored")
+	}
+
+	if code := put(`{"plugins": [{"name": "spread", "weight": 2}]}`); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	raw, ok := s.GetConfig(scheduler.ConfigKey)
+	if !ok || string(raw) != `{"plugins":[{"name":"spread","weight":2}]}` {
+		t.Errorf("Unexpected stored config: %s", raw)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config/scheduler", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"spread","weight":2`) {
+		t.Errorf("Expected effective config with spread enabled, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/bit2swaz/orion/internal/worker"
+)
+
+// ExecIDHeader carries the ID of a new exec session in the upgrade response,
+// for resizing its tty and reading its exit code afterwards.
+const ExecIDHeader = "X-Orion-Exec-ID"
+
+// handleTaskExec runs ?cmd=... inside a task's container. Like Docker's own
+// attach API, the client asks for "Upgrade: tcp" and after the 101 response
+// the connection carries stdin one way and the output the other; without a
+// tty the output is multiplexed with stdcopy headers.
+func (s *Server) handleTaskExec(w http.ResponseWriter, r *http.Request, t *task.Task) {
+	q := r.URL.Query()
+	opts := worker.ExecOptions{
+		Cmd:   q["cmd"],
+		Tty:   q.Get("tty") == "true",
+		Stdin: q.Get("stdin") == "true",
+	}
+	if len(opts.Cmd) == 0 {
+		http.Error(w, "missing cmd", http.StatusBadRequest)
+		return
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "tcp") {
+		http.Error(w, "exec needs a connection upgrade (Connection: Upgrade, Upgrade: tcp)", http.StatusUpgradeRequired)
+		return
+	}
+	if q.Has("h") || q.Has("w") {
+		height, errH := strconv.ParseUint(q.Get("h"), 10, 16)
+		width, errW := strconv.ParseUint(q.Get("w"), 10, 16)
+		if errH != nil || errW != nil {
+			http.Error(w, fmt.Sprintf("invalid console size %sx%s", q.Get("h"), q.Get("w")), http.StatusBadRequest)
+			return
+		}
+		opts.Height, opts.Width = uint(height), uint(width)
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return
+	}
+
+	session, err := s.Worker.Exec(r.Context(), t.ContainerID, opts)
+	if err != nil {
+		writeWorkerError(w, fmt.Errorf("exec in task %s: %w", t.ID, err))
+		return
+	}
+	defer session.Close()
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		log.Printf("Error hijacking exec connection for task %s: %v", t.ID, err)
+		return
+	}
+	defer conn.Close()
+
+	contentType := "application/vnd.docker.multiplexed-stream"
+	if opts.Tty {
+		contentType = "application/vnd.docker.raw-stream"
+	}
+	fmt.Fprintf(brw, "HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\nContent-Type: %s\r\n%s: %s\r\n\r\n", contentType, ExecIDHeader, session.ID)
+	if err := brw.Flush(); err != nil {
+		return
+	}
+
+	if opts.Stdin {
+		go func() {
+			io.Copy(session.Conn, brw.Reader)
+			session.CloseWrite()
+		}()
+	}
+	io.Copy(conn, session.Reader)
+}
+
+func (s *Server) handleInspectExec(w http.ResponseWriter, r *http.Request, t *task.Task) {
+	info, ok := s.taskExec(w, r, t)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+func (s *Server) handleResizeExec(w http.ResponseWriter, r *http.Request, t *task.Task) {
+	height, errH := strconv.ParseUint(r.URL.Query().Get("h"), 10, 16)
+	width, errW := strconv.ParseUint(r.URL.Query().Get("w"), 10, 16)
+	if errH != nil || errW != nil {
+		http.Error(w, fmt.Sprintf("invalid console size %sx%s", r.URL.Query().Get("h"), r.URL.Query().Get("w")), http.StatusBadRequest)
+		return
+	}
+	info, ok := s.taskExec(w, r, t)
+	if !ok {
+		return
+	}
+	if err := s.Worker.ResizeExec(r.Context(), info.ID, uint(height), uint(width)); err != nil {
+		writeWorkerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// taskExec looks up exec session {exec}, which must belong to t's container.
+func (s *Server) taskExec(w http.ResponseWriter, r *http.Request, t *task.Task) (worker.ExecInfo, bool) {
+	info, err := s.Worker.InspectExec(r.Context(), r.PathValue("exec"))
+	if err != nil {
+		writeWorkerError(w, err)
+		return info, false
+	}
+	if info.ContainerID != t.ContainerID {
+		http.Error(w, fmt.Sprintf("exec %s does not belong to task %s", r.PathValue("exec"), t.ID), http.StatusNotFound)
+		return info, false
+	}
+	return info, true
+}
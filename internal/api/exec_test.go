@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/bit2swaz/orion/internal/worker"
+	"github.com/google/uuid"
+)
+
+func TestServer_TaskExecValidation(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+	srv.Worker = &worker.Worker{Name: "node-1"}
+
+	running := task.Task{ID: uuid.New(), Name: "web", NodeID: "node-1", State: task.Running, ContainerID: "c1"}
+	if err := s.ApplyCommand(store.CommandTaskEvent, task.TaskEvent{ID: running.ID, State: task.Running, Task: running}); err != nil {
+		t.Fatalf("Failed to seed task: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		upgrade bool
+		want    int
+	}{
+		{"Missing Command", "", true, http.StatusBadRequest},
+		{"No Upgrade", "?cmd=sh", false, http.StatusUpgradeRequired},
+		{"Bad Console Size", "?cmd=sh&tty=true&h=24&w=wide", true, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/tasks/"+running.ID.String()+"/exec"+tt.query, nil)
+			if tt.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "tcp")
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+// ForwardedHeader marks a request that has already been proxied once, so a
+// node that lost leadership in the meantime fails it instead of bouncing it
+// around the cluster.
+const ForwardedHeader = "X-Orion-Forwarded-By"
+
+// leader wraps a handler that must run on the Raft leader. Followers proxy
+// the request to the current leader and relay its response. Reads only go to
+// the leader when the caller asks for ?consistent=true.
+func (s *Server) leader(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Store.IsLeader() {
+			h(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && r.URL.Query().Get("consistent") != "true" {
+			h(w, r)
+			return
+		}
+		s.forwardToLeader(w, r)
+	}
+}
+
+func (s *Server) forwardToLeader(w http.ResponseWriter, r *http.Request) {
+	if by := r.Header.Get(ForwardedHeader); by != "" {
+		http.Error(w, fmt.Sprintf("not the leader (request forwarded by %s)", by), http.StatusServiceUnavailable)
+		return
+	}
+
+	addr, err := s.Cluster.LeaderAPIAddr()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	s.forwardTo(w, r, addr)
+}
+
+func (s *Server) forwardTo(w http.ResponseWriter, r *http.Request, addr string) {
+	target := &url.URL{Scheme: "http", Host: addr}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("Error forwarding %s %s to %s: %v", r.Method, r.URL.Path, addr, err)
+		http.Error(w, fmt.Sprintf("forwarding to %s: %v", addr, err), http.StatusBadGateway)
+	}
+
+	r.Header.Set(ForwardedHeader, s.nodeID())
+	proxy.ServeHTTP(w, r)
+}
+
+// taskNode wraps a handler that works on the container of task {id}. Only
+// the node running the task can reach it, so other nodes proxy the request
+// there.
+func (s *Server) taskNode(h func(http.ResponseWriter, *http.Request, *task.Task)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := s.Store.GetTask(r.PathValue("id"))
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		if t.NodeID == "" || t.ContainerID == "" {
+			http.Error(w, fmt.Sprintf("task %s has no container yet (state %s)", t.ID, t.State), http.StatusConflict)
+			return
+		}
+
+		if s.Worker == nil || s.Worker.Name != t.NodeID {
+			s.forwardToNode(w, r, t.NodeID)
+			return
+		}
+		h(w, r, t)
+	}
+}
+
+// forwardToNode proxies a request that only the given node can serve.
+func (s *Server) forwardToNode(w http.ResponseWriter, r *http.Request, nodeID string) {
+	if by := r.Header.Get(ForwardedHeader); by != "" {
+		http.Error(w, fmt.Sprintf("task runs on %s, not %s (request forwarded by %s)", nodeID, s.nodeID(), by), http.StatusServiceUnavailable)
+		return
+	}
+	if s.Cluster == nil {
+		http.Error(w, fmt.Sprintf("cannot reach node %s", nodeID), http.StatusServiceUnavailable)
+		return
+	}
+
+	addr, err := s.Cluster.NodeAPIAddr(nodeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.forwardTo(w, r, addr)
+}
+
+func (s *Server) nodeID() string {
+	if s.Cluster == nil {
+		return "unknown"
+	}
+	return s.Cluster.NodeID
+}
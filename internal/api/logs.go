@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/bit2swaz/orion/internal/worker"
+)
+
+// handleTaskLogs streams the output of a task's container.
+func (s *Server) handleTaskLogs(w http.ResponseWriter, r *http.Request, t *task.Task) {
+	q := r.URL.Query()
+	opts := worker.LogOptions{
+		Follow:     q.Get("follow") == "true",
+		Tail:       q.Get("tail"),
+		Since:      q.Get("since"),
+		Timestamps: q.Get("timestamps") == "true",
+	}
+	if opts.Tail != "" && opts.Tail != "all" {
+		if n, err := strconv.Atoi(opts.Tail); err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("invalid tail %q", opts.Tail), http.StatusBadRequest)
+			return
+		}
+	}
+
+	out := &lazyWriter{w: w}
+	if err := s.Worker.Logs(r.Context(), t.ContainerID, opts, out, out); err != nil {
+		if out.started {
+			log.Printf("Error streaming logs of task %s: %v", t.ID, err)
+			return
+		}
+		writeWorkerError(w, fmt.Errorf("reading logs of task %s: %w", t.ID, err))
+		return
+	}
+	out.start()
+}
+
+// lazyWriter holds off the response header until there is output, so an
+// error from Docker can still become an error status, and flushes every
+// write so followed logs arrive as they are produced.
+type lazyWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (l *lazyWriter) start() {
+	if l.started {
+		return
+	}
+	l.started = true
+	l.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	l.w.WriteHeader(http.StatusOK)
+	if f, ok := l.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (l *lazyWriter) Write(p []byte) (int, error) {
+	l.start()
+	n, err := l.w.Write(p)
+	if f, ok := l.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
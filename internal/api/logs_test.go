@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+func TestServer_TaskLogsRouting(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	pending := task.Task{ID: uuid.New(), Name: "queued", State: task.Pending}
+	running := task.Task{ID: uuid.New(), Name: "web", NodeID: "node-2", State: task.Running, ContainerID: "c1"}
+	for _, tk := range []task.Task{pending, running} {
+		if err := s.ApplyCommand(store.CommandTaskEvent, task.TaskEvent{ID: tk.ID, State: tk.State, Task: tk}); err != nil {
+			t.Fatalf("Failed to seed task: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		id        string
+		forwarded bool
+		want      int
+	}{
+		{"Unknown Task", uuid.New().String(), false, http.StatusNotFound},
+		{"No Container Yet", pending.ID.String(), false, http.StatusConflict},
+		{"Remote Node Unreachable", running.ID.String(), false, http.StatusServiceUnavailable},
+		{"Already Forwarded", running.ID.String(), true, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/tasks/"+tt.id+"/logs?tail=10", nil)
+			if tt.forwarded {
+				req.Header.Set(ForwardedHeader, "node-3")
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
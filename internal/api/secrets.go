@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/secret"
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+// SecretInfo is what the API shows of a secret: never its value.
+type SecretInfo struct {
+	Name       string    `json:"name"`
+	Version    int       `json:"version"`
+	CreateTime time.Time `json:"createTime"`
+	UpdateTime time.Time `json:"updateTime"`
+}
+
+func secretInfo(sec *secret.Secret) SecretInfo {
+	return SecretInfo{Name: sec.Name, Version: sec.Version, CreateTime: sec.CreateTime, UpdateTime: sec.UpdateTime}
+}
+
+func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request) {
+	secrets, err := s.Store.ListSecrets()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	infos := make([]SecretInfo, 0, len(secrets))
+	for _, sec := range secrets {
+		infos = append(infos, secretInfo(sec))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(infos)
+}
+
+func (s *Server) handleGetSecret(w http.ResponseWriter, r *http.Request) {
+	sec, err := s.Store.GetSecret(r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(secretInfo(sec))
+}
+
+// handlePutSecret stores the request body as the value of a secret. The
+// receiving node seals it with the cluster key, so only ciphertext is
+// forwarded to the leader and written to the Raft log.
+func (s *Server) handlePutSecret(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := secret.ValidateName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.SecretKey == nil {
+		http.Error(w, "no cluster key configured on this node (--secrets-key-file)", http.StatusServiceUnavailable)
+		return
+	}
+
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, secret.MaxSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("secret value: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(value) == 0 {
+		http.Error(w, "secret value is empty", http.StatusBadRequest)
+		return
+	}
+
+	sealed, err := s.SecretKey.Seal(name, value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !s.Store.IsLeader() {
+		s.forwardToLeader(w, sealedSecretRequest(r, name, sealed))
+		return
+	}
+	s.putSealedSecret(w, name, sealed)
+}
+
+// sealedSecretRequest turns a PUT /secrets/{name} into the request that
+// hands its sealed value to the leader.
+func sealedSecretRequest(r *http.Request, name string, sealed []byte) *http.Request {
+	fwd := r.Clone(r.Context())
+	fwd.URL.Path = "/internal/secrets/" + name
+	fwd.URL.RawPath = ""
+	fwd.Body = io.NopCloser(bytes.NewReader(sealed))
+	fwd.ContentLength = int64(len(sealed))
+	fwd.Header.Set("Content-Type", "application/octet-stream")
+	return fwd
+}
+
+// handlePutSealedSecret stores a value a follower already sealed. It only
+// accepts ciphertext this node's cluster key opens.
+func (s *Server) handlePutSealedSecret(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := secret.ValidateName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.SecretKey == nil {
+		http.Error(w, "no cluster key configured on the leader (--secrets-key-file)", http.StatusServiceUnavailable)
+		return
+	}
+
+	sealed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 2*secret.MaxSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("sealed secret: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if _, err := s.SecretKey.Open(name, sealed); err != nil {
+		http.Error(w, fmt.Sprintf("secret %s is not sealed with the cluster key: %v", name, err), http.StatusBadRequest)
+		return
+	}
+	s.putSealedSecret(w, name, sealed)
+}
+
+func (s *Server) putSealedSecret(w http.ResponseWriter, name string, sealed []byte) {
+	sec, err := s.Store.PutSecret(name, sealed)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if sec.Version == 1 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(secretInfo(sec))
+}
+
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	if err := s.Store.DeleteSecret(r.PathValue("name")); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkSecrets makes sure every secret t refers to exists.
+func (s *Server) checkSecrets(t *task.Task) error {
+	for _, ref := range t.Secrets {
+		if _, err := s.Store.GetSecret(ref.Name); err != nil {
+			return fmt.Errorf("secret %s: %w", ref.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/secret"
+)
+
+func TestServer_Secrets(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	put := func(name, value string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/secrets/"+name, strings.NewReader(value)))
+		return rec
+	}
+
+	if rec := put("db-password", "hunter2"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 without a cluster key, got %d", rec.Code)
+	}
+
+	key, _ := secret.NewKey(bytes.Repeat([]byte{7}, secret.KeySize))
+	srv.SecretKey = key
+	if rec := put("db-password", "hunter2"); rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := put("db-password", "correct horse"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on update, got %d", rec.Code)
+	}
+
+	stored, _ := s.GetSecret("db-password")
+	if bytes.Contains(stored.Data, []byte("correct horse")) {
+		t.Fatal("Secret value stored in plaintext")
+	}
+	if value, err := key.Open("db-password", stored.Data); err != nil || string(value) != "correct horse" {
+		t.Fatalf("Stored secret does not open: %q, %v", value, err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/secrets", nil))
+	if strings.Contains(rec.Body.String(), "data") || strings.Contains(rec.Body.String(), "Data") {
+		t.Errorf("Secret listing exposes data: %s", rec.Body.String())
+	}
+	var list []SecretInfo
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list) != 1 || list[0].Name != "db-password" || list[0].Version != 2 {
+		t.Errorf("Unexpected listing %+v", list)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"image":"postgres","secrets":[{"name":"missing","env":"PW"}]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected task with an unknown secret to be rejected, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/secrets/db-password", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/secrets/db-password", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestServer_FollowerSealsSecrets(t *testing.T) {
+	key, _ := secret.NewKey(bytes.Repeat([]byte{7}, secret.KeySize))
+
+	leaderStore := openStore(t, true)
+	leaderSrv := New(leaderStore, nil)
+	leaderSrv.SecretKey = key
+	var gotBody []byte
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(gotBody))
+		leaderSrv.ServeHTTP(w, r)
+	}))
+	defer leader.Close()
+
+	follower := New(openStore(t, false), nil)
+	follower.SecretKey = key
+	sealed, _ := key.Seal("db-password", []byte("hunter2"))
+	req := httptest.NewRequest(http.MethodPut, "/secrets/db-password", strings.NewReader("hunter2"))
+	rec := httptest.NewRecorder()
+	follower.forwardTo(rec, sealedSecretRequest(req, "db-password", sealed), strings.TrimPrefix(leader.URL, "http://"))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 from the leader, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if bytes.Contains(gotBody, []byte("hunter2")) {
+		t.Fatal("Secret value forwarded in plaintext")
+	}
+	stored, _ := leaderStore.GetSecret("db-password")
+	if value, err := key.Open("db-password", stored.Data); err != nil || string(value) != "hunter2" {
+		t.Fatalf("Stored secret does not open: %q, %v", value, err)
+	}
+
+	rec = httptest.NewRecorder()
+	leaderSrv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/internal/secrets/db-password", strings.NewReader("hunter2")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a plaintext value to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bit2swaz/orion/internal/cluster"
+	"github.com/bit2swaz/orion/internal/secret"
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/worker"
+	"github.com/docker/docker/client"
+)
+
+type Server struct {
+	Store     *store.Store
+	Cluster   *cluster.Manager
+	Worker    *worker.Worker
+	SecretKey *secret.Key
+	mux       *http.ServeMux
+}
+
+func New(s *store.Store, c *cluster.Manager) *Server {
+	srv := &Server{
+		Store:   s,
+		Cluster: c,
+		mux:     http.NewServeMux(),
+	}
+
+	srv.mux.HandleFunc("GET /nodes", srv.handleNodes)
+	srv.mux.HandleFunc("GET /raft", srv.handleRaft)
+	srv.mux.HandleFunc("GET /tasks", srv.leader(srv.handleListTasks))
+	srv.mux.HandleFunc("GET /tasks/{id}", srv.leader(srv.handleGetTask))
+	srv.mux.HandleFunc("POST /tasks", srv.leader(srv.handleSubmitTask))
+	srv.mux.HandleFunc("DELETE /tasks/{id}", srv.leader(srv.handleStopTask))
+	srv.mux.HandleFunc("GET /tasks/{id}/logs", srv.taskNode(srv.handleTaskLogs))
+	srv.mux.HandleFunc("POST /tasks/{id}/exec", srv.taskNode(srv.handleTaskExec))
+	srv.mux.HandleFunc("GET /tasks/{id}/exec/{exec}", srv.taskNode(srv.handleInspectExec))
+	srv.mux.HandleFunc("POST /tasks/{id}/exec/{exec}/resize", srv.taskNode(srv.handleResizeExec))
+	srv.mux.HandleFunc("GET /services", srv.leader(srv.handleListServices))
+	srv.mux.HandleFunc("GET /services/{name}", srv.leader(srv.handleGetService))
+	srv.mux.HandleFunc("POST /services", srv.leader(srv.handleCreateService))
+	srv.mux.HandleFunc("PUT /services/{name}", srv.leader(srv.handleUpdateService))
+	srv.mux.HandleFunc("DELETE /services/{name}", srv.leader(srv.handleDeleteService))
+	srv.mux.HandleFunc("GET /services/{name}/history", srv.leader(srv.handleServiceHistory))
+	srv.mux.HandleFunc("POST /services/{name}/rollback", srv.leader(srv.handleRollbackService))
+	srv.mux.HandleFunc("POST /services/{name}/promote", srv.leader(srv.handlePromoteService))
+	srv.mux.HandleFunc("POST /services/{name}/abort", srv.leader(srv.handleAbortService))
+	srv.mux.HandleFunc("POST /services/{name}/pause", srv.leader(srv.handlePauseService))
+	srv.mux.HandleFunc("POST /services/{name}/resume", srv.leader(srv.handleResumeService))
+	srv.mux.HandleFunc("GET /secrets", srv.leader(srv.handleListSecrets))
+	srv.mux.HandleFunc("GET /secrets/{name}", srv.leader(srv.handleGetSecret))
+	srv.mux.HandleFunc("PUT /secrets/{name}", srv.handlePutSecret)
+	srv.mux.HandleFunc("DELETE /secrets/{name}", srv.leader(srv.handleDeleteSecret))
+	srv.mux.HandleFunc("GET /config/scheduler", srv.leader(srv.handleGetSchedulerConfig))
+	srv.mux.HandleFunc("PUT /config/scheduler", srv.leader(srv.handlePutSchedulerConfig))
+	srv.mux.HandleFunc("POST /internal/events", srv.leader(srv.handleTaskEvent))
+	srv.mux.HandleFunc("PUT /internal/secrets/{name}", srv.leader(srv.handlePutSealedSecret))
+
+	return srv
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	members := s.Cluster.Members()
+	var nodes []map[string]interface{}
+	for _, m := range members {
+		var meta cluster.NodeMeta
+		json.Unmarshal(m.Meta, &meta)
+
+		node := map[string]interface{}{
+			"name":   m.Name,
+			"ip":     m.Addr.String(),
+			"role":   "worker",
+			"status": "alive",
+			"cpu":    meta.CpuTotal,
+			"ram":    meta.MemoryTotal,
+			"meta":   meta,
+		}
+		nodes = append(nodes, node)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(nodes)
+}
+
+func (s *Server) handleRaft(w http.ResponseWriter, r *http.Request) {
+	state := "Follower"
+	if s.Store.IsLeader() {
+		state = "Leader"
+	}
+	tasks, _ := s.Store.ListTasks()
+	resp := map[string]interface{}{
+		"state":     state,
+		"leader":    s.Store.LeaderID(),
+		"taskCount": len(tasks),
+		"tasks":     tasks,
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrTaskNotFound), errors.Is(err, store.ErrServiceNotFound), errors.Is(err, store.ErrRevisionNotFound),
+		errors.Is(err, store.ErrSecretNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, store.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeWorkerError reports a failed Docker call made for a task's container.
+func writeWorkerError(w http.ResponseWriter, err error) {
+	if client.IsErrNotFound(err) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
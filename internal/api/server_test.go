@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+func openStore(t *testing.T, bootstrap bool) *store.Store {
+	s := store.New()
+	if err := s.Open(t.TempDir(), "node-1", "127.0.0.1:0", bootstrap); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.R.Shutdown().Error() })
+
+	if bootstrap {
+		deadline := time.Now().Add(5 * time.Second)
+		for !s.IsLeader() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Node did not become leader after bootstrap")
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	return s
+}
+
+func TestServer_SubmitTaskOnLeader(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"name":"web","image":"nginx"}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var created task.Task
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	stored, err := s.GetTask(created.ID.String())
+	if err != nil {
+		t.Fatalf("Task not found in store: %v", err)
+	}
+	if stored.Image != "nginx" || stored.State != task.Pending {
+		t.Errorf("Unexpected stored task: %+v", stored)
+	}
+}
+
+func TestServer_TaskEventFromWorker(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	scheduled := task.Task{ID: uuid.New(), Name: "web", NodeID: "node-2", State: task.Scheduled}
+	data, _ := json.Marshal(task.TaskEvent{ID: scheduled.ID, State: task.Scheduled, Task: scheduled})
+	if err := s.R.Apply(data, 5*time.Second).Error(); err != nil {
+		t.Fatalf("Failed to seed task: %v", err)
+	}
+
+	post := func(event task.TaskEvent) int {
+		body, _ := json.Marshal(event)
+		req := httptest.NewRequest(http.MethodPost, "/internal/events", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	running := scheduled
+	if code := post(task.TaskEvent{ID: scheduled.ID, State: task.Running, Timestamp: time.Now(), Task: running}); code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	stored, _ := s.GetTask(scheduled.ID.String())
+	if stored.State != task.Running {
+		t.Errorf("Expected state Running, got %v", stored.State)
+	}
+
+	running.NodeID = "node-3"
+	if code := post(task.TaskEvent{ID: scheduled.ID, State: task.Failed, Timestamp: time.Now(), Task: running}); code != http.StatusConflict {
+		t.Errorf("Expected 409 for event from wrong node, got %d", code)
+	}
+}
+
+func TestServer_StopTask(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	pending := task.Task{ID: uuid.New(), Name: "batch", State: task.Pending}
+	if err := s.ApplyCommand(store.CommandTaskEvent, task.TaskEvent{ID: pending.ID, State: task.Pending, Task: pending}); err != nil {
+		t.Fatalf("Failed to seed task: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/tasks/"+pending.ID.String(), nil))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	stored, _ := s.GetTask(pending.ID.String())
+	if !stored.DesiredStop || stored.State != task.Completed || stored.FinishTime.IsZero() {
+		t.Errorf("Expected pending task to be stopped and completed, got %+v", stored)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/tasks/"+uuid.New().String(), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown task, got %d", rec.Code)
+	}
+}
+
+func TestServer_ForwardsWritesToLeader(t *testing.T) {
+	s := openStore(t, false)
+	srv := New(s, nil)
+
+	var gotPath, gotForwardedBy string
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotForwardedBy = r.Header.Get(ForwardedHeader)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer leader.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"image":"nginx"}`))
+	rec := httptest.NewRecorder()
+	srv.forwardTo(rec, req, strings.TrimPrefix(leader.URL, "http://"))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected leader status 201, got %d", rec.Code)
+	}
+	if gotPath != "/tasks" {
+		t.Errorf("Expected path /tasks, got %s", gotPath)
+	}
+	if gotForwardedBy == "" {
+		t.Errorf("Expected %s header to be set", ForwardedHeader)
+	}
+	if strings.TrimSpace(rec.Body.String()) != `{"ok":true}` {
+		t.Errorf("Expected leader body to be relayed, got %s", rec.Body.String())
+	}
+}
+
+func TestServer_RejectsDoubleForward(t *testing.T) {
+	s := openStore(t, false)
+	srv := New(s, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"image":"nginx"}`))
+	req.Header.Set(ForwardedHeader, "node-2")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", rec.Code)
+	}
+}
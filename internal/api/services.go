@@ -0,0 +1,218 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/service"
+)
+
+func (s *Server) handleListServices(w http.ResponseWriter, r *http.Request) {
+	services, err := s.Store.ListServices()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if services == nil {
+		services = []*service.Service{}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(services)
+}
+
+func (s *Server) handleGetService(w http.ResponseWriter, r *http.Request) {
+	svc, err := s.Store.GetService(r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(svc)
+}
+
+func (s *Server) handleCreateService(w http.ResponseWriter, r *http.Request) {
+	var svc service.Service
+	if err := json.NewDecoder(r.Body).Decode(&svc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := svc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkSecrets(&svc.Template); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := s.Store.CreateService(svc)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+func (s *Server) handleUpdateService(w http.ResponseWriter, r *http.Request) {
+	var svc service.Service
+	if err := json.NewDecoder(r.Body).Decode(&svc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	svc.Name = r.PathValue("name")
+	if err := svc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkSecrets(&svc.Template); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updated, err := s.Store.UpdateService(svc)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (s *Server) handleDeleteService(w http.ResponseWriter, r *http.Request) {
+	if err := s.Store.DeleteService(r.PathValue("name")); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleServiceHistory(w http.ResponseWriter, r *http.Request) {
+	history, err := s.Store.ServiceHistory(r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if history == nil {
+		history = []service.Revision{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+func (s *Server) handleRollbackService(w http.ResponseWriter, r *http.Request) {
+	version, err := intParam(r.URL.Query().Get("to"), 0)
+	if err != nil || version < 0 {
+		http.Error(w, "invalid to parameter", http.StatusBadRequest)
+		return
+	}
+
+	svc, err := s.Store.RollbackService(r.PathValue("name"), version)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(svc)
+}
+
+func (s *Server) handlePromoteService(w http.ResponseWriter, r *http.Request) {
+	svc, err := s.Store.GetService(r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if svc.Update.Strategy != service.StrategyCanary || svc.Status.State != service.UpdateRolling || svc.Status.Promoted {
+		http.Error(w, fmt.Sprintf("service %s has no canary awaiting promotion", svc.Name), http.StatusConflict)
+		return
+	}
+
+	status := svc.Status
+	status.Promoted = true
+	status.Message = ""
+	s.writeServiceStatus(w, svc, status)
+}
+
+func (s *Server) handleAbortService(w http.ResponseWriter, r *http.Request) {
+	svc, err := s.Store.GetService(r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	switch svc.Status.State {
+	case service.UpdateRolling, service.UpdatePaused, service.UpdateHalted:
+	default:
+		http.Error(w, fmt.Sprintf("service %s has no update in progress", svc.Name), http.StatusConflict)
+		return
+	}
+
+	aborted, err := s.Store.AbortServiceUpdate(svc.Name)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(aborted)
+}
+
+func (s *Server) handlePauseService(w http.ResponseWriter, r *http.Request) {
+	s.setUpdateState(w, r, service.UpdatePaused, service.UpdateRolling)
+}
+
+func (s *Server) handleResumeService(w http.ResponseWriter, r *http.Request) {
+	s.setUpdateState(w, r, service.UpdateRolling, service.UpdatePaused, service.UpdateHalted)
+}
+
+func (s *Server) setUpdateState(w http.ResponseWriter, r *http.Request, to service.UpdateState, from ...service.UpdateState) {
+	svc, err := s.Store.GetService(r.PathValue("name"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	allowed := false
+	for _, st := range from {
+		allowed = allowed || svc.Status.State == st
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("service %s: update is %q, cannot move it to %q", svc.Name, svc.Status.State, to), http.StatusConflict)
+		return
+	}
+
+	status := svc.Status
+	status.State = to
+	status.Message = ""
+	if to == service.UpdateRolling {
+		status.StartTime = time.Now()
+	}
+	s.writeServiceStatus(w, svc, status)
+}
+
+func (s *Server) writeServiceStatus(w http.ResponseWriter, svc *service.Service, status service.UpdateStatus) {
+	if err := s.Store.SetServiceStatus(svc.Name, svc.Version, status); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	updated, err := s.Store.GetService(svc.Name)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/service"
+)
+
+func TestServer_ServicesCRUD(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	do := func(method, path, body string) (int, service.Service) {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var svc service.Service
+		if rec.Code < 300 {
+			json.NewDecoder(rec.Body).Decode(&svc)
+		}
+		return rec.Code, svc
+	}
+
+	code, svc := do(http.MethodPost, "/services", `{"name":"web","replicas":3,"template":{"image":"nginx"}}`)
+	if code != http.StatusCreated || svc.Version != 1 {
+		t.Fatalf("Expected 201 with version 1, got %d %+v", code, svc)
+	}
+
+	if code, _ := do(http.MethodPost, "/services", `{"name":"web","replicas":1,"template":{"image":"nginx"}}`); code != http.StatusConflict {
+		t.Errorf("Expected 409 for duplicate service, got %d", code)
+	}
+	if code, _ := do(http.MethodPost, "/services", `{"name":"Bad_Name","replicas":1,"template":{"image":"nginx"}}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid name, got %d", code)
+	}
+	if code, _ := do(http.MethodPost, "/services", `{"name":"noimage","replicas":1}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for missing image, got %d", code)
+	}
+
+	code, svc = do(http.MethodPut, "/services/web", `{"replicas":5,"template":{"image":"nginx"}}`)
+	if code != http.StatusOK || svc.Replicas != 5 || svc.Version != 1 {
+		t.Errorf("Expected replica change without new version, got %d %+v", code, svc)
+	}
+	code, svc = do(http.MethodPut, "/services/web", `{"replicas":5,"template":{"image":"nginx:1.27"}}`)
+	if code != http.StatusOK || svc.Version != 2 {
+		t.Errorf("Expected template change to bump version, got %d %+v", code, svc)
+	}
+
+	if code, svc = do(http.MethodGet, "/services/web", ""); code != http.StatusOK || svc.Template.Image != "nginx:1.27" {
+		t.Errorf("Unexpected service: %d %+v", code, svc)
+	}
+
+	if code, _ := do(http.MethodDelete, "/services/web", ""); code != http.StatusAccepted {
+		t.Errorf("Expected 202, got %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/services/web", ""); code != http.StatusNotFound {
+		t.Errorf("Expected 404 after delete, got %d", code)
+	}
+	if code, _ := do(http.MethodPut, "/services/web", `{"replicas":1,"template":{"image":"nginx"}}`); code != http.StatusNotFound {
+		t.Errorf("Expected 404 updating missing service, got %d", code)
+	}
+}
+
+func TestServer_PauseResumeUpdate(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	do := func(method, path, body string) (int, service.Service) {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var svc service.Service
+		if rec.Code < 300 {
+			json.NewDecoder(rec.Body).Decode(&svc)
+		}
+		return rec.Code, svc
+	}
+
+	do(http.MethodPost, "/services", `{"name":"web","replicas":2,"template":{"image":"nginx:1.26"}}`)
+	if code, _ := do(http.MethodPost, "/services/web/pause", ""); code != http.StatusConflict {
+		t.Errorf("Expected 409 pausing without an update, got %d", code)
+	}
+
+	code, svc := do(http.MethodPut, "/services/web", `{"replicas":2,"update":{"maxSurge":2},"template":{"image":"nginx:1.27"}}`)
+	if code != http.StatusOK || svc.Status.State != service.UpdateRolling || svc.Update.MaxSurge != 2 {
+		t.Fatalf("Expected update to start, got %d %+v", code, svc)
+	}
+
+	if code, svc = do(http.MethodPost, "/services/web/pause", ""); code != http.StatusOK || svc.Status.State != service.UpdatePaused {
+		t.Errorf("Expected paused update, got %d %+v", code, svc.Status)
+	}
+	if code, svc = do(http.MethodPost, "/services/web/resume", ""); code != http.StatusOK || svc.Status.State != service.UpdateRolling {
+		t.Errorf("Expected resumed update, got %d %+v", code, svc.Status)
+	}
+	if code, _ := do(http.MethodPost, "/services/web/resume", ""); code != http.StatusConflict {
+		t.Errorf("Expected 409 resuming a running update, got %d", code)
+	}
+
+	if code, _ := do(http.MethodPut, "/services/web", `{"replicas":2,"update":{"maxSurge":-1},"template":{"image":"nginx:1.27"}}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for negative maxSurge, got %d", code)
+	}
+}
+
+func TestServer_ServiceHistoryAndRollback(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	do(http.MethodPost, "/services", `{"name":"web","replicas":2,"template":{"image":"nginx:1.25"}}`)
+	do(http.MethodPut, "/services/web", `{"replicas":2,"template":{"image":"nginx:1.26"}}`)
+	do(http.MethodPut, "/services/web", `{"replicas":4,"template":{"image":"nginx:1.26"}}`)
+	do(http.MethodPut, "/services/web", `{"replicas":4,"template":{"image":"nginx:1.27"}}`)
+
+	var history []service.Revision
+	rec := do(http.MethodGet, "/services/web/history", "")
+	json.NewDecoder(rec.Body).Decode(&history)
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(history))
+	}
+	for i, rev := range history {
+		if rev.Version != i+1 {
+			t.Errorf("Revision %d has version %d", i, rev.Version)
+		}
+	}
+
+	var svc service.Service
+	rec = do(http.MethodPost, "/services/web/rollback", "")
+	json.NewDecoder(rec.Body).Decode(&svc)
+	if rec.Code != http.StatusOK || svc.Version != 4 || svc.Template.Image != "nginx:1.26" || svc.Replicas != 4 {
+		t.Fatalf("Expected rollback to 1.26 as version 4, got %d %+v", rec.Code, svc)
+	}
+	if svc.Status.State != service.UpdateRolling {
+		t.Errorf("Expected rollback to start an update, got %q", svc.Status.State)
+	}
+
+	rec = do(http.MethodPost, "/services/web/rollback?to=1", "")
+	json.NewDecoder(rec.Body).Decode(&svc)
+	if rec.Code != http.StatusOK || svc.Version != 5 || svc.Template.Image != "nginx:1.25" {
+		t.Fatalf("Expected rollback to 1.25 as version 5, got %d %+v", rec.Code, svc)
+	}
+
+	rec = do(http.MethodGet, "/services/web/history", "")
+	json.NewDecoder(rec.Body).Decode(&history)
+	if last := history[len(history)-1]; last.Cause != "rollback to version 1" {
+		t.Errorf("Unexpected cause for latest revision: %q", last.Cause)
+	}
+
+	if rec := do(http.MethodPost, "/services/web/rollback?to=5", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 rolling back to the current version, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/services/web/rollback?to=42", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown revision, got %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/services/web/rollback?to=x", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid version, got %d", rec.Code)
+	}
+}
+
+func TestServer_PromoteAndAbort(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	do := func(method, path, body string) (int, service.Service) {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var svc service.Service
+		if rec.Code < 300 {
+			json.NewDecoder(rec.Body).Decode(&svc)
+		}
+		return rec.Code, svc
+	}
+
+	do(http.MethodPost, "/services", `{"name":"web","replicas":3,"update":{"strategy":"canary"},"template":{"image":"nginx:1.26"}}`)
+	if code, _ := do(http.MethodPost, "/services/web/promote", ""); code != http.StatusConflict {
+		t.Errorf("Expected 409 promoting without an update, got %d", code)
+	}
+	if code, _ := do(http.MethodPost, "/services/web/abort", ""); code != http.StatusConflict {
+		t.Errorf("Expected 409 aborting without an update, got %d", code)
+	}
+
+	do(http.MethodPut, "/services/web", `{"replicas":3,"update":{"strategy":"canary"},"template":{"image":"nginx:1.27"}}`)
+	code, svc := do(http.MethodPost, "/services/web/promote", "")
+	if code != http.StatusOK || !svc.Status.Promoted {
+		t.Errorf("Expected promoted update, got %d %+v", code, svc.Status)
+	}
+	if code, _ := do(http.MethodPost, "/services/web/promote", ""); code != http.StatusConflict {
+		t.Errorf("Expected 409 promoting twice, got %d", code)
+	}
+
+	code, svc = do(http.MethodPost, "/services/web/abort", "")
+	if code != http.StatusOK || svc.Version != 3 || svc.Template.Image != "nginx:1.26" {
+		t.Errorf("Expected abort to restore 1.26 as version 3, got %d %+v", code, svc)
+	}
+
+	if code, _ := do(http.MethodPut, "/services/web", `{"replicas":3,"update":{"strategy":"yolo"},"template":{"image":"nginx:1.26"}}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown strategy, got %d", code)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+type TaskList struct {
+	Tasks  []*task.Task `json:"tasks"`
+	Total  int          `json:"total"`
+	Offset int          `json:"offset"`
+	Limit  int          `json:"limit"`
+}
+
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var state *task.State
+	if v := q.Get("state"); v != "" {
+		st, err := task.ParseState(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		state = &st
+	}
+
+	offset, err := intParam(q.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		http.Error(w, fmt.Sprintf("invalid offset %q", q.Get("offset")), http.StatusBadRequest)
+		return
+	}
+	limit, err := intParam(q.Get("limit"), defaultPageLimit)
+	if err != nil || limit <= 0 || limit > maxPageLimit {
+		http.Error(w, fmt.Sprintf("invalid limit %q (1-%d)", q.Get("limit"), maxPageLimit), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := s.Store.ListTasks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	filtered := []*task.Task{}
+	for _, t := range tasks {
+		if state != nil && t.State != *state {
+			continue
+		}
+		if node := q.Get("node"); node != "" && t.NodeID != node {
+			continue
+		}
+		if name := q.Get("name"); name != "" && t.Name != name {
+			continue
+		}
+		if svc := q.Get("service"); svc != "" && t.ServiceName != svc {
+			continue
+		}
+		filtered = append(filtered, t)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		if !filtered[i].StartTime.Equal(filtered[j].StartTime) {
+			return filtered[i].StartTime.Before(filtered[j].StartTime)
+		}
+		return filtered[i].ID.String() < filtered[j].ID.String()
+	})
+
+	page := TaskList{Tasks: []*task.Task{}, Total: len(filtered), Offset: offset, Limit: limit}
+	if offset < len(filtered) {
+		end := min(offset+limit, len(filtered))
+		page.Tasks = filtered[offset:end]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	t, err := s.Store.GetTask(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+func (s *Server) handleSubmitTask(w http.ResponseWriter, r *http.Request) {
+	var t task.Task
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := t.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.checkSecrets(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// only the spec comes from the client
+	t.ResetRuntime()
+	t.ID = uuid.New()
+	t.StartTime = time.Now()
+
+	event := task.TaskEvent{
+		ID:        t.ID,
+		State:     task.Pending,
+		Timestamp: time.Now(),
+		Task:      t,
+	}
+
+	if err := s.Store.ApplyCommand(store.CommandTaskEvent, event); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+func (s *Server) handleStopTask(w http.ResponseWriter, r *http.Request) {
+	t, err := s.Store.StopTask(r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(t)
+}
+
+func (s *Server) handleTaskEvent(w http.ResponseWriter, r *http.Request) {
+	var event task.TaskEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Store.ReportTaskEvent(event); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func intParam(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
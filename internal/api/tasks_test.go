@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+func TestServer_ListAndGetTasks(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	base := time.Now()
+	seed := []task.Task{
+		{ID: uuid.New(), Name: "web", NodeID: "node-1", State: task.Running, StartTime: base},
+		{ID: uuid.New(), Name: "web", NodeID: "node-2", State: task.Running, StartTime: base.Add(time.Second)},
+		{ID: uuid.New(), Name: "batch", NodeID: "node-1", State: task.Scheduled, StartTime: base.Add(2 * time.Second)},
+		{ID: uuid.New(), Name: "batch", State: task.Pending, StartTime: base.Add(3 * time.Second)},
+	}
+	for _, tk := range seed {
+		if err := s.ApplyCommand(store.CommandTaskEvent, task.TaskEvent{ID: tk.ID, State: tk.State, Task: tk}); err != nil {
+			t.Fatalf("Failed to seed task: %v", err)
+		}
+	}
+
+	list := func(query string) (int, TaskList) {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks"+query, nil))
+		var page TaskList
+		if rec.Code == http.StatusOK {
+			json.NewDecoder(rec.Body).Decode(&page)
+		}
+		return rec.Code, page
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		wantIDs []uuid.UUID
+		total   int
+	}{
+		{name: "All In Start Order", query: "", wantIDs: []uuid.UUID{seed[0].ID, seed[1].ID, seed[2].ID, seed[3].ID}, total: 4},
+		{name: "By State", query: "?state=running", wantIDs: []uuid.UUID{seed[0].ID, seed[1].ID}, total: 2},
+		{name: "By Node", query: "?node=node-1", wantIDs: []uuid.UUID{seed[0].ID, seed[2].ID}, total: 2},
+		{name: "By Name And State", query: "?name=batch&state=pending", wantIDs: []uuid.UUID{seed[3].ID}, total: 1},
+		{name: "Second Page", query: "?limit=2&offset=2", wantIDs: []uuid.UUID{seed[2].ID, seed[3].ID}, total: 4},
+		{name: "Past The End", query: "?offset=10", wantIDs: nil, total: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, page := list(tt.query)
+			if code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", code)
+			}
+			if page.Total != tt.total {
+				t.Errorf("Expected total %d, got %d", tt.total, page.Total)
+			}
+			if len(page.Tasks) != len(tt.wantIDs) {
+				t.Fatalf("Expected %d tasks, got %d", len(tt.wantIDs), len(page.Tasks))
+			}
+			for i, id := range tt.wantIDs {
+				if page.Tasks[i].ID != id {
+					t.Errorf("Position %d: expected %s, got %s", i, id, page.Tasks[i].ID)
+				}
+			}
+		})
+	}
+
+	for _, query := range []string{"?state=sleeping", "?limit=0", "?offset=-1"} {
+		if code, _ := list(query); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+seed[2].ID.String(), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var got task.Task
+	json.NewDecoder(rec.Body).Decode(&got)
+	if got.ID != seed[2].ID || got.Name != "batch" {
+		t.Errorf("Unexpected task: %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks/"+uuid.New().String(), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestServer_SubmitTaskValidatesSpec(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"Valid Spec", `{"image":"nginx","env":{"PORT":"80"},"mounts":[{"type":"volume","source":"data","target":"/data"}],"readOnlyRootfs":true}`, http.StatusCreated},
+		{"Bad Mount", `{"image":"nginx","mounts":[{"type":"bind","source":"data","target":"/data"}]}`, http.StatusBadRequest},
+		{"Reserved Label", `{"image":"nginx","labels":{"orion.task.id":"x"}}`, http.StatusBadRequest},
+		{"Bad Health Check", `{"image":"nginx","healthCheck":{"type":"http"}}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_SubmitTaskClearsServerFields(t *testing.T) {
+	s := openStore(t, true)
+	srv := New(s, nil)
+
+	body := `{"image":"nginx","ServiceName":"web","ServiceVersion":3,"NodeID":"node-2","ContainerID":"abc123","AvoidNodes":["node-1"],"RestartCount":4,"DesiredStop":true,"History":[{"Message":"forged"}]}`
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var created task.Task
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	stored, err := s.GetTask(created.ID.String())
+	if err != nil {
+		t.Fatalf("Task not found in store: %v", err)
+	}
+	if stored.ServiceName != "" || stored.ServiceVersion != 0 || stored.NodeID != "" || stored.ContainerID != "" {
+		t.Errorf("Expected server-owned fields cleared, got service %q v%d node %q container %q", stored.ServiceName, stored.ServiceVersion, stored.NodeID, stored.ContainerID)
+	}
+	if stored.AvoidNodes != nil || stored.RestartCount != 0 || stored.DesiredStop || len(stored.History) != 1 {
+		t.Errorf("Expected fresh task state, got %+v", stored)
+	}
+}
@@ -0,0 +1,41 @@
+package cluster
+
+import (
+	"log"
+	"runtime"
+)
+
+type Fingerprint struct {
+	MemoryTotal int64
+	MemoryUsed  int64
+	CpuTotal    float64
+	Load1       float64
+	Load5       float64
+	Load15      float64
+	DiskTotal   int64
+	DiskUsed    int64
+}
+
+func Collect(dataDir string) Fingerprint {
+	fp := Fingerprint{CpuTotal: float64(runtime.NumCPU())}
+
+	if total, used, err := memoryUsage(); err != nil {
+		log.Printf("Fingerprint: failed to read memory usage: %v", err)
+	} else {
+		fp.MemoryTotal, fp.MemoryUsed = total, used
+	}
+
+	if l1, l5, l15, err := loadAverage(); err != nil {
+		log.Printf("Fingerprint: failed to read load average: %v", err)
+	} else {
+		fp.Load1, fp.Load5, fp.Load15 = l1, l5, l15
+	}
+
+	if total, used, err := diskUsage(dataDir); err != nil {
+		log.Printf("Fingerprint: failed to read disk usage of %s: %v", dataDir, err)
+	} else {
+		fp.DiskTotal, fp.DiskUsed = total, used
+	}
+
+	return fp
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package cluster
+
+import "errors"
+
+var errFingerprintUnsupported = errors.New("resource fingerprinting is only supported on linux")
+
+func memoryUsage() (int64, int64, error) {
+	return 0, 0, errFingerprintUnsupported
+}
+
+func loadAverage() (float64, float64, float64, error) {
+	return 0, 0, 0, errFingerprintUnsupported
+}
+
+func diskUsage(path string) (int64, int64, error) {
+	return 0, 0, errFingerprintUnsupported
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"runtime"
+	"net"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/bit2swaz/orion/internal/store"
@@ -18,23 +20,42 @@ type NodeMeta struct {
 	MemoryTotal int64   `json:"mem_total"`
 	MemoryUsed  int64   `json:"mem_used"`
 	CpuTotal    float64 `json:"cpu_total"`
+	Load1       float64 `json:"load_1"`
+	Load5       float64 `json:"load_5"`
+	Load15      float64 `json:"load_15"`
+	DiskTotal   int64   `json:"disk_total"`
+	DiskUsed    int64   `json:"disk_used"`
 	RaftPort    int     `json:"raft_port"`
+	ApiPort     int     `json:"api_port"`
 }
 
+const MetaRefreshInterval = 10 * time.Second
+
 type Manager struct {
 	list     *memberlist.Memberlist
 	store    *store.Store
 	NodeID   string
 	Role     string
 	RaftPort int
+	ApiPort  int
+	DataDir  string
+
+	mu          sync.RWMutex
+	fingerprint Fingerprint
+	stop        chan struct{}
+	stopOnce    sync.Once
 }
 
-func New(bindPort int, raftPort int, nodeID string, role string, s *store.Store) (*Manager, error) {
+func New(bindPort int, raftPort int, apiPort int, nodeID string, role string, dataDir string, s *store.Store) (*Manager, error) {
 	m := &Manager{
-		NodeID:   nodeID,
-		Role:     role,
-		RaftPort: raftPort,
-		store:    s,
+		NodeID:      nodeID,
+		Role:        role,
+		RaftPort:    raftPort,
+		ApiPort:     apiPort,
+		DataDir:     dataDir,
+		store:       s,
+		fingerprint: Collect(dataDir),
+		stop:        make(chan struct{}),
 	}
 
 	conf := GetLifeguardConfig()
@@ -48,6 +69,7 @@ func New(bindPort int, raftPort int, nodeID string, role string, s *store.Store)
 		return nil, err
 	}
 	m.list = list
+	go m.refreshMeta()
 
 	return m, nil
 }
@@ -67,21 +89,79 @@ func (m *Manager) Join(peers []string) (int, error) {
 }
 
 func (m *Manager) Leave() error {
+	m.stopOnce.Do(func() { close(m.stop) })
 	return m.list.Leave(time.Second)
 }
 
+func (m *Manager) refreshMeta() {
+	ticker := time.NewTicker(MetaRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			fp := Collect(m.DataDir)
+			m.mu.Lock()
+			m.fingerprint = fp
+			m.mu.Unlock()
+
+			if err := m.list.UpdateNode(MetaRefreshInterval); err != nil {
+				log.Printf("Failed to propagate node meta: %v", err)
+			}
+		}
+	}
+}
+
 func (m *Manager) Members() []*memberlist.Node {
 	return m.list.Members()
 }
 
+func (m *Manager) LeaderAPIAddr() (string, error) {
+	leaderID := m.store.LeaderID()
+	if leaderID == "" {
+		return "", fmt.Errorf("no raft leader elected")
+	}
+	return m.NodeAPIAddr(leaderID)
+}
+
+func (m *Manager) NodeAPIAddr(nodeID string) (string, error) {
+	for _, member := range m.list.Members() {
+		if member.Name != nodeID {
+			continue
+		}
+
+		var meta NodeMeta
+		if err := json.Unmarshal(member.Meta, &meta); err != nil {
+			return "", fmt.Errorf("failed to parse meta for %s: %v", nodeID, err)
+		}
+		if meta.ApiPort == 0 {
+			return "", fmt.Errorf("node %s does not advertise an API port", nodeID)
+		}
+		return net.JoinHostPort(member.Addr.String(), strconv.Itoa(meta.ApiPort)), nil
+	}
+	return "", fmt.Errorf("node %s is not a cluster member", nodeID)
+}
+
 func (m *Manager) NodeMeta(limit int) []byte {
+	m.mu.RLock()
+	fp := m.fingerprint
+	m.mu.RUnlock()
+
 	meta := NodeMeta{
 		ID:          m.NodeID,
 		Role:        m.Role,
-		MemoryTotal: 8 * 1024 * 1024 * 1024,
-		MemoryUsed:  1 * 1024 * 1024 * 1024,
-		CpuTotal:    float64(runtime.NumCPU()),
+		MemoryTotal: fp.MemoryTotal,
+		MemoryUsed:  fp.MemoryUsed,
+		CpuTotal:    fp.CpuTotal,
+		Load1:       fp.Load1,
+		Load5:       fp.Load5,
+		Load15:      fp.Load15,
+		DiskTotal:   fp.DiskTotal,
+		DiskUsed:    fp.DiskUsed,
 		RaftPort:    m.RaftPort,
+		ApiPort:     m.ApiPort,
 	}
 	b, _ := json.Marshal(meta)
 	return b
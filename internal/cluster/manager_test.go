@@ -1,7 +1,9 @@
 package cluster
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,7 +21,7 @@ func TestCluster_Join(t *testing.T) {
 	time.Sleep(2 * time.Second)
 
 	gossipPortA := 17946
-	nodeA, err := New(gossipPortA, raftPortA, "NodeA", "manager", storeA)
+	nodeA, err := New(gossipPortA, raftPortA, 18080, "NodeA", "manager", dirA, storeA)
 	if err != nil {
 		t.Fatalf("Failed to create Node A: %v", err)
 	}
@@ -34,7 +36,7 @@ func TestCluster_Join(t *testing.T) {
 	}
 
 	gossipPortB := 18946
-	nodeB, err := New(gossipPortB, raftPortB, "NodeB", "worker", storeB)
+	nodeB, err := New(gossipPortB, raftPortB, 18081, "NodeB", "worker", dirB, storeB)
 	if err != nil {
 		t.Fatalf("Failed to create Node B: %v", err)
 	}
@@ -60,4 +62,25 @@ func TestCluster_Join(t *testing.T) {
 	if err := future.Error(); err != nil {
 		t.Fatalf("Failed to get Raft configuration: %v", err)
 	}
+
+	for _, member := range nodeB.Members() {
+		if member.Name != "NodeA" {
+			continue
+		}
+		var meta NodeMeta
+		if err := json.Unmarshal(member.Meta, &meta); err != nil {
+			t.Fatalf("Failed to parse Node A meta: %v", err)
+		}
+		if meta.MemoryTotal <= 0 || meta.CpuTotal <= 0 || meta.DiskTotal <= 0 {
+			t.Errorf("Expected real resources in Node A meta, got %+v", meta)
+		}
+	}
+
+	apiAddr, err := nodeB.NodeAPIAddr("NodeA")
+	if err != nil {
+		t.Fatalf("Node B failed to resolve Node A API address: %v", err)
+	}
+	if !strings.HasSuffix(apiAddr, ":18080") {
+		t.Errorf("Expected Node A API address on port 18080, got %s", apiAddr)
+	}
 }
@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/bit2swaz/orion/internal/worker"
+	"github.com/docker/docker/client"
+	"github.com/google/uuid"
+)
+
+const driftListTimeout = 10 * time.Second
+
+type drift struct {
+	task    *task.Task
+	message string
+}
+
+// leftover is a container this node no longer needs.
+type leftover struct {
+	container worker.Container
+	reason    string
+}
+
+// detectDrift compares this node's running tasks with the containers Docker
+// actually has. A task whose container is missing or stopped fails, and its
+// restart policy decides whether it comes back.
+func (m *Manager) detectDrift(tasks []*task.Task) {
+	if m.Worker == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), driftListTimeout)
+	defer cancel()
+	containers, err := m.Worker.List(ctx)
+	if err != nil {
+		log.Printf("Error listing containers: %v", err)
+		return
+	}
+
+	busy := make(map[uuid.UUID]bool)
+	for _, id := range m.execPool().ids() {
+		busy[id] = true
+	}
+
+	drifted, stale := findDrift(m.LocalID, tasks, containers, busy)
+	for _, d := range drifted {
+		log.Printf("Task %s drifted: %s", d.task.ID, d.message)
+		m.reportDrift(d)
+	}
+	for _, l := range stale {
+		m.removeLeftover(l)
+	}
+}
+
+// findDrift returns the local running tasks whose container is missing or
+// stopped, and the containers left here by tasks that were deleted, purged
+// or moved to another node, e.g. while this node was partitioned away.
+// Tasks in busy have an operation in flight and are skipped.
+func findDrift(localID string, tasks []*task.Task, containers []worker.Container, busy map[uuid.UUID]bool) ([]drift, []leftover) {
+	byTask := make(map[uuid.UUID]worker.Container)
+	byID := make(map[string]worker.Container)
+	for _, c := range containers {
+		if c.TaskID != uuid.Nil {
+			byTask[c.TaskID] = c
+		}
+		byID[c.ID] = c
+	}
+
+	known := make(map[uuid.UUID]bool)
+	assigned := make(map[uuid.UUID]bool)
+	deleted := make(map[uuid.UUID]bool)
+	left := make(map[string]bool)
+	var drifted []drift
+	for _, t := range tasks {
+		known[t.ID] = true
+		if t.NodeID != localID {
+			if t.PrevNodeID == localID && t.PrevContainerID != "" {
+				left[t.PrevContainerID] = true
+			}
+			continue
+		}
+		assigned[t.ID] = true
+		if t.DesiredStop && (t.State == task.Completed || t.State == task.Failed) && !busy[t.ID] {
+			deleted[t.ID] = true
+		}
+		if t.State != task.Running || t.DesiredStop || busy[t.ID] {
+			continue
+		}
+
+		c, ok := byTask[t.ID]
+		if !ok && t.ContainerID != "" {
+			c, ok = byID[t.ContainerID]
+		}
+		switch {
+		case !ok:
+			drifted = append(drifted, drift{task: t, message: "container missing"})
+		case !c.Present():
+			drifted = append(drifted, drift{task: t, message: fmt.Sprintf("container %s", c.State)})
+		}
+	}
+
+	var stale []leftover
+	for _, c := range containers {
+		switch {
+		case deleted[c.TaskID]:
+			stale = append(stale, leftover{c, "task was deleted"})
+		case c.TaskID != uuid.Nil && !known[c.TaskID]:
+			stale = append(stale, leftover{c, "task was purged"})
+		case c.TaskID != uuid.Nil && !assigned[c.TaskID], left[c.ID]:
+			stale = append(stale, leftover{c, "task moved to another node"})
+		}
+	}
+	return drifted, stale
+}
+
+// removeLeftover stops and removes a container this node no longer needs,
+// along with its task's secret files, so a task never runs twice and a
+// deleted task doesn't keep holding its name.
+func (m *Manager) removeLeftover(l leftover) {
+	c := l.container
+	m.execPool().start(c.TaskID, opStop, m.StopTimeout, func(ctx context.Context) {
+		log.Printf("Removing container %s of task %s: %s", c.ID, c.TaskID, l.reason)
+		if err := m.Worker.Stop(ctx, c.ID); err != nil && !client.IsErrNotFound(err) {
+			log.Printf("Error stopping container %s: %v", c.ID, err)
+			return
+		}
+		if err := m.Worker.Remove(ctx, c.ID); err != nil && !client.IsErrNotFound(err) {
+			log.Printf("Error removing container %s: %v", c.ID, err)
+			return
+		}
+		if c.TaskID == uuid.Nil {
+			return
+		}
+		if err := m.Worker.RemoveSecrets(c.TaskID); err != nil {
+			log.Printf("Error removing secret files of task %s: %v", c.TaskID, err)
+		}
+	})
+}
+
+func (m *Manager) reportDrift(d drift) {
+	t := d.task
+	event := task.TaskEvent{
+		ID:        t.ID,
+		State:     task.Failed,
+		Timestamp: time.Now(),
+		Task:      *t,
+	}
+	event.Task.Drift = &task.DriftReport{Time: time.Now(), Message: d.message}
+
+	if err := m.reportEvent(event); err != nil {
+		log.Printf("Error reporting drift of task %s: %v", t.ID, err)
+	}
+}
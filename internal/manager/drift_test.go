@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/bit2swaz/orion/internal/worker"
+	"github.com/google/uuid"
+	"github.com/hashicorp/raft"
+)
+
+func TestFindDrift(t *testing.T) {
+	running := &task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Running, ContainerID: "c-running"}
+	legacy := &task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Running, ContainerID: "c-legacy"}
+	missing := &task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Running, ContainerID: "c-gone"}
+	exited := &task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Running, ContainerID: "c-exited"}
+	starting := &task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Running}
+	stopping := &task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Running, DesiredStop: true}
+	failed := &task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Failed, ContainerID: "c-failed"}
+	deleted := &task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Failed, ContainerID: "c-deleted", DesiredStop: true}
+	elsewhere := &task.Task{ID: uuid.New(), NodeID: "node-2", State: task.Running}
+	moved := &task.Task{ID: uuid.New(), NodeID: "node-2", State: task.Running, PrevNodeID: "node-1", PrevContainerID: "c-left"}
+	movedLegacy := &task.Task{ID: uuid.New(), NodeID: "node-3", State: task.Running, PrevNodeID: "node-1", PrevContainerID: "c-left-legacy"}
+
+	containers := []worker.Container{
+		{ID: "c-running", TaskID: running.ID, State: "running"},
+		{ID: "c-legacy", State: "running"},
+		{ID: "c-exited", TaskID: exited.ID, State: "exited"},
+		{ID: "c-failed", TaskID: failed.ID, State: "exited"},
+		{ID: "c-deleted", TaskID: deleted.ID, State: "exited"},
+		{ID: "c-moved", TaskID: elsewhere.ID, State: "running"},
+		{ID: "c-orphan", TaskID: uuid.New(), State: "running"},
+		{ID: "c-foreign", State: "running"},
+		{ID: "c-left", TaskID: moved.ID, State: "running"},
+		{ID: "c-left-legacy", State: "exited"},
+	}
+	tasks := []*task.Task{running, legacy, missing, exited, starting, stopping, failed, deleted, elsewhere, moved, movedLegacy}
+	busy := map[uuid.UUID]bool{starting.ID: true}
+
+	drifted, stale := findDrift("node-1", tasks, containers, busy)
+
+	got := make(map[uuid.UUID]drift)
+	for _, d := range drifted {
+		got[d.task.ID] = d
+	}
+	if len(got) != 2 {
+		t.Errorf("Expected 2 drifted tasks, got %d", len(got))
+	}
+	if d, ok := got[missing.ID]; !ok || d.message != "container missing" {
+		t.Errorf("Expected missing container to be reported, got %+v", d)
+	}
+	if d, ok := got[exited.ID]; !ok || d.message != "container exited" {
+		t.Errorf("Expected exited container to be reported, got %+v", d)
+	}
+
+	staleIDs := make(map[string]string)
+	for _, l := range stale {
+		staleIDs[l.container.ID] = l.reason
+	}
+	want := map[string]string{
+		"c-deleted":     "task was deleted",
+		"c-orphan":      "task was purged",
+		"c-moved":       "task moved to another node",
+		"c-left":        "task moved to another node",
+		"c-left-legacy": "task moved to another node",
+	}
+	if len(staleIDs) != len(want) {
+		t.Errorf("Expected %d stale containers, got %v", len(want), staleIDs)
+	}
+	for id, reason := range want {
+		if staleIDs[id] != reason {
+			t.Errorf("Expected %s to be stale because %s, got %q", id, reason, staleIDs[id])
+		}
+	}
+}
+
+func TestFindDrift_LostTaskRejoins(t *testing.T) {
+	s := store.New()
+	id := uuid.New()
+	apply := func(typ store.CommandType, payload interface{}) {
+		data, _ := store.NewCommand(typ, payload)
+		if resp := s.Apply(&raft.Log{Data: data}); resp != nil {
+			t.Fatalf("Apply %s returned %v", typ, resp)
+		}
+	}
+
+	running := task.Task{ID: id, NodeID: "node-1", State: task.Running, ContainerID: "c1"}
+	apply(store.CommandTaskEvent, task.TaskEvent{ID: id, State: task.Running, Task: running})
+	apply(store.CommandTaskLost, store.TaskLost{ID: id, NodeID: "node-1", Timestamp: time.Now(), Reason: "node-1 unreachable"})
+
+	lost, _ := s.GetTask(id.String())
+	if lost.PrevNodeID != "node-1" || lost.PrevContainerID != "c1" {
+		t.Fatalf("Expected the lost container to be recorded, got %q %q", lost.PrevNodeID, lost.PrevContainerID)
+	}
+	rescheduled := *lost
+	rescheduled.NodeID = "node-2"
+	rescheduled.State = task.Scheduled
+	apply(store.CommandTaskEvent, task.TaskEvent{ID: id, State: task.Scheduled, Task: rescheduled})
+
+	// node-1 comes back still running the task's old container
+	tasks, _ := s.ListTasks()
+	for _, c := range []worker.Container{
+		{ID: "c1", TaskID: id, State: "running"},
+		{ID: "c1", State: "running"},
+	} {
+		_, stale := findDrift("node-1", tasks, []worker.Container{c}, nil)
+		if len(stale) != 1 || stale[0].container.ID != "c1" {
+			t.Errorf("Expected node-1 to remove the old container %+v, got %+v", c, stale)
+		}
+	}
+	if _, stale := findDrift("node-2", tasks, []worker.Container{{ID: "c2", TaskID: id, State: "running"}}, nil); len(stale) != 0 {
+		t.Errorf("Expected node-2 to keep the task's new container, got %+v", stale)
+	}
+}
@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"log"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/bit2swaz/orion/internal/worker"
+)
+
+// handleExit records how a local task's container terminated.
+func (m *Manager) handleExit(exit worker.Exit) {
+	t, err := m.Store.GetTask(exit.TaskID.String())
+	if err != nil || !exitApplies(t, exit, m.LocalID) {
+		return
+	}
+
+	state := task.Completed
+	if exit.Code != 0 || exit.OOMKilled {
+		state = task.Failed
+	}
+	log.Printf("Task %s %s", t.ID, exit.Reason)
+
+	event := task.TaskEvent{
+		ID:        t.ID,
+		State:     state,
+		Timestamp: exit.Time,
+		Task:      *t,
+	}
+	event.Task.ContainerID = exit.ContainerID
+	event.Task.Exit = &task.ExitStatus{
+		Time:      exit.Time,
+		Code:      exit.Code,
+		OOMKilled: exit.OOMKilled,
+		Reason:    exit.Reason,
+	}
+
+	if err := m.reportEvent(event); err != nil {
+		log.Printf("Error reporting exit of task %s: %v", t.ID, err)
+	}
+}
+
+// exitApplies reports whether exit ends t: the task runs here, is not being
+// stopped on purpose, and the exit is from its current container, or from a
+// new one not yet reported for a task that is still starting.
+func exitApplies(t *task.Task, exit worker.Exit, localID string) bool {
+	if t.NodeID != localID || t.DesiredStop {
+		return false
+	}
+	switch t.State {
+	case task.Running:
+		return t.ContainerID == exit.ContainerID
+	case task.Scheduled:
+		// a restarted task still points at the container it ran in
+		// before; only an exit of the one started since counts
+		return t.ContainerID != exit.ContainerID
+	}
+	return false
+}
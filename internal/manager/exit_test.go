@@ -0,0 +1,35 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/bit2swaz/orion/internal/worker"
+)
+
+func TestExitApplies(t *testing.T) {
+	exit := worker.Exit{ContainerID: "c1"}
+
+	tests := []struct {
+		name string
+		task task.Task
+		want bool
+	}{
+		{"Running", task.Task{NodeID: "node-1", State: task.Running, ContainerID: "c1"}, true},
+		{"Still Starting", task.Task{NodeID: "node-1", State: task.Scheduled}, true},
+		{"Restart Started", task.Task{NodeID: "node-1", State: task.Scheduled, ContainerID: "c0", RestartCount: 1}, true},
+		{"Replayed Before Restart", task.Task{NodeID: "node-1", State: task.Scheduled, ContainerID: "c1", RestartCount: 1}, false},
+		{"Other Node", task.Task{NodeID: "node-2", State: task.Running, ContainerID: "c1"}, false},
+		{"Stopping", task.Task{NodeID: "node-1", State: task.Running, ContainerID: "c1", DesiredStop: true}, false},
+		{"Already Finished", task.Task{NodeID: "node-1", State: task.Failed, ContainerID: "c1"}, false},
+		{"Previous Container", task.Task{NodeID: "node-1", State: task.Running, ContainerID: "c2"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitApplies(&tt.task, exit, "node-1"); got != tt.want {
+				t.Errorf("exitApplies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
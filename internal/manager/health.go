@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+type healthCheck struct {
+	containerID string
+	cancel      context.CancelFunc
+}
+
+// syncHealthChecks starts a checker for every local running task with a
+// health check and stops the checkers of tasks that no longer need one.
+func (m *Manager) syncHealthChecks(tasks []*task.Task) {
+	if m.Worker == nil {
+		return
+	}
+	if m.healthChecks == nil {
+		m.healthChecks = make(map[uuid.UUID]*healthCheck)
+	}
+
+	for _, t := range tasks {
+		wanted := t.NodeID == m.LocalID && t.State == task.Running && !t.DesiredStop &&
+			t.HealthCheck != nil && t.ContainerID != ""
+		hc, ok := m.healthChecks[t.ID]
+		if ok && (!wanted || hc.containerID != t.ContainerID) {
+			m.stopHealthCheck(t.ID)
+			ok = false
+		}
+		if wanted && !ok {
+			ctx, cancel := context.WithCancel(context.Background())
+			m.healthChecks[t.ID] = &healthCheck{containerID: t.ContainerID, cancel: cancel}
+			go m.runHealthCheck(ctx, t)
+		}
+	}
+}
+
+func (m *Manager) stopHealthCheck(id uuid.UUID) {
+	if hc, ok := m.healthChecks[id]; ok {
+		hc.cancel()
+		delete(m.healthChecks, id)
+	}
+}
+
+// healthCounter turns probe results into health changes once a threshold
+// of passing or failing probes in a row is reached.
+type healthCounter struct {
+	healthyAfter, unhealthyAfter int
+	passes, failures             int
+	status                       task.HealthStatus
+}
+
+// observe records a probe result and returns the new status, if it changed.
+func (c *healthCounter) observe(err error) (task.HealthStatus, bool) {
+	if err == nil {
+		c.passes, c.failures = c.passes+1, 0
+		if c.passes >= c.healthyAfter && c.status != task.Healthy {
+			c.status = task.Healthy
+			return c.status, true
+		}
+		return c.status, false
+	}
+	c.passes, c.failures = 0, c.failures+1
+	if c.failures >= c.unhealthyAfter && c.status != task.Unhealthy {
+		c.status = task.Unhealthy
+		return c.status, true
+	}
+	return c.status, false
+}
+
+func (m *Manager) runHealthCheck(ctx context.Context, t *task.Task) {
+	check := *t.HealthCheck
+	counter := healthCounter{}
+	counter.healthyAfter, counter.unhealthyAfter = check.Thresholds()
+	if t.Health != nil {
+		counter.status = t.Health.Status
+	}
+
+	ticker := time.NewTicker(check.Interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := m.Worker.Probe(ctx, t.ContainerID, check)
+		if ctx.Err() != nil {
+			return
+		}
+		status, changed := counter.observe(err)
+		if !changed {
+			continue
+		}
+
+		msg := fmt.Sprintf("%d %s check(s) passed", counter.passes, check.Type)
+		if err != nil {
+			msg = fmt.Sprintf("%d %s check(s) failed: %v", counter.failures, check.Type, err)
+		}
+		m.reportHealth(ctx, t, status, msg)
+		if status == task.Unhealthy && check.Replace {
+			return
+		}
+	}
+}
+
+// reportHealth records a health change. An unhealthy task whose check asks
+// for replacement is stopped and failed, so its restart policy or service
+// brings up a new one.
+func (m *Manager) reportHealth(ctx context.Context, t *task.Task, status task.HealthStatus, msg string) {
+	log.Printf("Task %s is %s: %s", t.ID, status, msg)
+
+	event := task.TaskEvent{
+		ID:        t.ID,
+		State:     task.Running,
+		Timestamp: time.Now(),
+		Task:      *t,
+	}
+	event.Task.Health = &task.HealthReport{Time: time.Now(), Status: status, Message: msg}
+
+	if status == task.Unhealthy && t.HealthCheck.Replace {
+		if err := m.Worker.Stop(ctx, t.ContainerID); err != nil {
+			log.Printf("Error stopping unhealthy task %s: %v", t.ID, err)
+		}
+		event.State = task.Failed
+	}
+
+	if err := m.reportEvent(event); err != nil {
+		log.Printf("Error reporting health of task %s: %v", t.ID, err)
+	}
+}
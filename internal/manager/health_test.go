@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+func TestHealthCounter(t *testing.T) {
+	fail := errors.New("connection refused")
+	c := healthCounter{healthyAfter: 2, unhealthyAfter: 3}
+
+	steps := []struct {
+		err     error
+		status  task.HealthStatus
+		changed bool
+	}{
+		{nil, "", false},
+		{nil, task.Healthy, true},
+		{nil, task.Healthy, false},
+		{fail, task.Healthy, false},
+		{fail, task.Healthy, false},
+		{nil, task.Healthy, false},
+		{fail, task.Healthy, false},
+		{fail, task.Healthy, false},
+		{fail, task.Unhealthy, true},
+		{fail, task.Unhealthy, false},
+		{nil, task.Unhealthy, false},
+		{nil, task.Healthy, true},
+	}
+
+	for i, s := range steps {
+		status, changed := c.observe(s.err)
+		if status != s.status || changed != s.changed {
+			t.Fatalf("Step %d: got (%q, %v), want (%q, %v)", i, status, changed, s.status, s.changed)
+		}
+	}
+}
@@ -1,45 +1,123 @@
 package manager
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/bit2swaz/orion/internal/cluster"
 	"github.com/bit2swaz/orion/internal/scheduler"
+	"github.com/bit2swaz/orion/internal/secret"
 	"github.com/bit2swaz/orion/internal/store"
 	"github.com/bit2swaz/orion/internal/task"
 	"github.com/bit2swaz/orion/internal/worker"
+	"github.com/docker/docker/client"
+	"github.com/google/uuid"
+	"github.com/hashicorp/memberlist"
+)
+
+const (
+	DefaultPurgeAfter      = time.Minute
+	DefaultNodeGracePeriod = 30 * time.Second
+	DefaultResyncInterval  = 10 * time.Second
+
+	DefaultExecConcurrency = 4
+	DefaultExecTimeout     = 10 * time.Minute
+	DefaultStopTimeout     = time.Minute
 )
 
 type Manager struct {
-	Store     *store.Store
-	Scheduler *scheduler.Scheduler
-	Worker    *worker.Worker
-	Cluster   *cluster.Manager
-	LocalID   string
+	Store      *store.Store
+	Scheduler  *scheduler.Scheduler
+	Worker     *worker.Worker
+	Cluster    *cluster.Manager
+	LocalID    string
+	PurgeAfter time.Duration
+
+	NodeGracePeriod time.Duration
+	ResyncInterval  time.Duration
+
+	// ExecConcurrency bounds how many containers this node starts or stops
+	// at once; ExecTimeout and StopTimeout bound each of those operations.
+	ExecConcurrency int
+	ExecTimeout     time.Duration
+	StopTimeout     time.Duration
+
+	// SecretKey opens the secrets of tasks started on this node.
+	SecretKey *secret.Key
+
+	schedulerConfig json.RawMessage
+	missingSince    map[string]time.Time
+	pool            *execPool
+
+	nextWake     time.Time
+	healthChecks map[uuid.UUID]*healthCheck
 }
 
 func New(store *store.Store, scheduler *scheduler.Scheduler, worker *worker.Worker, cluster *cluster.Manager, localID string) *Manager {
 	return &Manager{
-		Store:     store,
-		Scheduler: scheduler,
-		Worker:    worker,
-		Cluster:   cluster,
-		LocalID:   localID,
+		Store:      store,
+		Scheduler:  scheduler,
+		Worker:     worker,
+		Cluster:    cluster,
+		LocalID:    localID,
+		PurgeAfter: DefaultPurgeAfter,
+
+		NodeGracePeriod: DefaultNodeGracePeriod,
+		ResyncInterval:  DefaultResyncInterval,
+		ExecConcurrency: DefaultExecConcurrency,
+		ExecTimeout:     DefaultExecTimeout,
+		StopTimeout:     DefaultStopTimeout,
+		missingSince:    make(map[string]time.Time),
 	}
 }
 
+// Run reconciles whenever the store applies a change, and every
+// ResyncInterval to catch up on anything driven by time rather than by
+// changes, such as node grace periods and purging.
 func (m *Manager) Run(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
+	m.setDefaults()
+
+	watcher := m.Store.Watch()
+	defer m.Store.Unwatch(watcher)
+
+	ticker := time.NewTicker(m.ResyncInterval)
 	defer ticker.Stop()
 
+	exits := make(chan worker.Exit)
+	if m.Worker != nil {
+		go m.Worker.WatchExits(ctx, exits)
+	}
+
+	wake := time.NewTimer(time.Hour)
+	wake.Stop()
+
+	m.Reconcile()
 	for {
+		if !m.nextWake.IsZero() {
+			wake.Reset(time.Until(m.nextWake))
+			m.nextWake = time.Time{}
+		}
+
 		select {
 		case <-ctx.Done():
 			return
+		case <-wake.C:
+			m.Reconcile()
+		case exit := <-exits:
+			m.handleExit(exit)
+		case <-watcher.C:
+			if ids, all := watcher.Drain(); all {
+				m.Reconcile()
+			} else {
+				m.reconcileChanged(ids)
+			}
 		case <-ticker.C:
 			m.Reconcile()
 		}
@@ -53,98 +131,405 @@ func (m *Manager) Reconcile() {
 		return
 	}
 
+	m.reconcileLocal(tasks)
+	m.cancelVanished(tasks)
+	m.detectDrift(tasks)
+	m.pruneSecrets(tasks)
+	m.reconcileCluster(tasks)
+}
+
+// reconcileChanged only revisits the local tasks in ids; the leader's
+// cluster-wide pass still sees every task since placement depends on all
+// of them.
+func (m *Manager) reconcileChanged(ids []uuid.UUID) {
+	var changed []*task.Task
+	for _, id := range ids {
+		t, err := m.Store.GetTask(id.String())
+		if err != nil {
+			m.cancelExec(id)
+			m.stopHealthCheck(id)
+			continue
+		}
+		changed = append(changed, t)
+	}
+	m.reconcileLocal(changed)
+
+	if !m.Store.IsLeader() {
+		clear(m.missingSince)
+		return
+	}
+	tasks, err := m.Store.ListTasks()
+	if err != nil {
+		log.Printf("Error listing tasks: %v", err)
+		return
+	}
+	m.reconcileCluster(tasks)
+}
+
+// reconcileLocal hands starts and stops of this node's tasks to the
+// execution pool, so a slow image pull never holds up the loop.
+func (m *Manager) reconcileLocal(tasks []*task.Task) {
+	pool := m.execPool()
+	for _, t := range tasks {
+		if t.NodeID != m.LocalID {
+			continue
+		}
+		pool.observe(t.ID, t)
+		if t.DesiredStop && (t.State == task.Scheduled || t.State == task.Running) {
+			m.cancelExec(t.ID)
+			pool.start(t.ID, opStop, m.StopTimeout, func(ctx context.Context) { m.stopTask(ctx, t) })
+		} else if t.State == task.Scheduled && !t.DesiredStop {
+			pool.start(t.ID, opExec, m.ExecTimeout, func(ctx context.Context) {
+				// a follower may reconcile again before the report
+				// comes back through raft; don't start the task twice
+				pool.settle(t.ID, t, settleTimeout)
+				m.execTask(ctx, t)
+			})
+		}
+	}
+	m.syncHealthChecks(tasks)
+}
+
+// wakeAt makes Run reconcile again at t, for work that is due before the
+// next resync.
+func (m *Manager) wakeAt(t time.Time) {
+	if m.nextWake.IsZero() || t.Before(m.nextWake) {
+		m.nextWake = t
+	}
+}
+
+// setDefaults replaces settings a Manager can't run with, such as those of
+// a Manager not built by New, with their defaults.
+func (m *Manager) setDefaults() {
+	if m.ResyncInterval <= 0 {
+		m.ResyncInterval = DefaultResyncInterval
+	}
+	if m.ExecConcurrency <= 0 {
+		m.ExecConcurrency = DefaultExecConcurrency
+	}
+	if m.ExecTimeout <= 0 {
+		m.ExecTimeout = DefaultExecTimeout
+	}
+	if m.StopTimeout <= 0 {
+		m.StopTimeout = DefaultStopTimeout
+	}
+	if m.NodeGracePeriod < 0 {
+		m.NodeGracePeriod = DefaultNodeGracePeriod
+	}
+}
+
+func (m *Manager) execPool() *execPool {
+	if m.pool == nil {
+		m.pool = newExecPool(m.ExecConcurrency)
+	}
+	return m.pool
+}
+
+func (m *Manager) cancelExec(id uuid.UUID) {
+	if m.execPool().cancel(id, opExec) {
+		log.Printf("Cancelling start of task %s", id)
+	}
+}
+
+// cancelVanished cancels starts and health checks of tasks that were purged
+// from the store.
+func (m *Manager) cancelVanished(tasks []*task.Task) {
+	known := make(map[uuid.UUID]bool, len(tasks))
 	for _, t := range tasks {
-		if t.State == task.Scheduled && t.NodeID == m.LocalID {
-			m.execTask(t)
+		known[t.ID] = true
+	}
+	for _, id := range m.execPool().ids() {
+		if !known[id] {
+			m.cancelExec(id)
 		}
 	}
+	for id := range m.healthChecks {
+		if !known[id] {
+			m.stopHealthCheck(id)
+		}
+	}
+}
 
+func (m *Manager) reconcileCluster(tasks []*task.Task) {
 	if m.Store.IsLeader() {
+		m.markLostTasks(tasks)
+		m.restartTasks(tasks)
+		m.reconcileServices(tasks)
 		m.scheduleTasks(tasks)
+		m.purgeTasks(tasks)
+	} else {
+		clear(m.missingSince)
 	}
 }
 
-func (m *Manager) execTask(t *task.Task) {
-	ctx := context.Background()
-	_, err := m.Worker.Run(ctx, *t)
+func (m *Manager) execTask(ctx context.Context, t *task.Task) {
+	if t.RestartCount > 0 {
+		// the previous run's container still holds the task's name
+		if ref := containerRef(t); ref != "" {
+			if err := m.Worker.Remove(ctx, ref); err != nil && !client.IsErrNotFound(err) {
+				log.Printf("Error removing previous container of task %s: %v", t.ID, err)
+			}
+		}
+	}
+
+	state := task.Running
+	containerID := ""
+	secrets, err := m.resolveSecrets(t)
+	if err == nil {
+		containerID, err = m.Worker.Run(ctx, *t, secrets)
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "Conflict") || strings.Contains(err.Error(), "already in use") {
+		switch {
+		case errors.Is(ctx.Err(), context.Canceled):
+			// the task was stopped or purged while starting; clean up
+			// whatever got created
+			log.Printf("Start of task %s cancelled", t.ID)
+			stopCtx, cancel := context.WithTimeout(context.Background(), m.StopTimeout)
+			defer cancel()
+			m.stopTask(stopCtx, t)
+			return
+		case strings.Contains(err.Error(), "Conflict") || strings.Contains(err.Error(), "already in use"):
 			log.Printf("Task %s already running", t.ID)
-			t.State = task.Running
-		} else {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			log.Printf("Error running task %s: timed out after %s: %v", t.ID, m.ExecTimeout, err)
+			state = task.Failed
+		default:
 			log.Printf("Error running task %s: %v", t.ID, err)
-			t.State = task.Failed
+			state = task.Failed
 		}
-	} else {
-		t.State = task.Running
 	}
 
-	if m.Store.IsLeader() {
-		event := task.TaskEvent{
+	event := task.TaskEvent{
+		ID:        t.ID,
+		State:     state,
+		Timestamp: time.Now(),
+		Task:      *t,
+	}
+	event.Task.ContainerID = containerID
+
+	if err := m.reportEvent(event); err != nil {
+		log.Printf("Error reporting task %s state %v: %v", t.ID, state, err)
+	}
+}
+
+func (m *Manager) stopTask(ctx context.Context, t *task.Task) {
+	if ref := containerRef(t); ref != "" {
+		if err := m.Worker.Stop(ctx, ref); err != nil && !client.IsErrNotFound(err) {
+			log.Printf("Error stopping task %s: %v", t.ID, err)
+			return
+		}
+		if err := m.Worker.Remove(ctx, ref); err != nil && !client.IsErrNotFound(err) {
+			log.Printf("Error removing container for task %s: %v", t.ID, err)
+			return
+		}
+	}
+	if err := m.Worker.RemoveSecrets(t.ID); err != nil {
+		log.Printf("Error removing secret files of task %s: %v", t.ID, err)
+	}
+
+	event := task.TaskEvent{
+		ID:        t.ID,
+		State:     task.Completed,
+		Timestamp: time.Now(),
+		Task:      *t,
+	}
+
+	if err := m.reportEvent(event); err != nil {
+		log.Printf("Error reporting task %s stopped: %v", t.ID, err)
+	}
+}
+
+func (m *Manager) markLostTasks(tasks []*task.Task) {
+	for _, t := range m.findLostTasks(tasks, m.Cluster.Members(), time.Now()) {
+		lost := store.TaskLost{
 			ID:        t.ID,
-			State:     t.State,
+			NodeID:    t.NodeID,
 			Timestamp: time.Now(),
-			Task:      *t,
+			Reason:    fmt.Sprintf("node %s unreachable for more than %s", t.NodeID, m.NodeGracePeriod),
 		}
 
-		data, err := json.Marshal(event)
-		if err != nil {
-			log.Printf("Error marshaling event: %v", err)
-			return
+		log.Printf("Task %s lost with node %s, rescheduling", t.ID, t.NodeID)
+		if err := m.Store.ApplyCommand(store.CommandTaskLost, lost); err != nil {
+			log.Printf("Error marking task %s lost: %v", t.ID, err)
 		}
+	}
+}
 
-		if future := m.Store.R.Apply(data, 10*time.Second); future.Error() != nil {
-			log.Printf("Error applying to Raft: %v", future.Error())
+// findLostTasks returns the Scheduled/Running tasks whose node has been
+// missing from the gossip membership for longer than NodeGracePeriod.
+func (m *Manager) findLostTasks(tasks []*task.Task, members []*memberlist.Node, now time.Time) []*task.Task {
+	alive := make(map[string]bool, len(members))
+	for _, member := range members {
+		alive[member.Name] = true
+	}
+	for nodeID := range m.missingSince {
+		if alive[nodeID] {
+			delete(m.missingSince, nodeID)
+		}
+	}
+
+	var lost []*task.Task
+	for _, t := range tasks {
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+		if t.NodeID == "" || alive[t.NodeID] {
+			continue
+		}
+
+		since, ok := m.missingSince[t.NodeID]
+		if !ok {
+			m.missingSince[t.NodeID] = now
+			continue
+		}
+		if now.Sub(since) >= m.NodeGracePeriod {
+			lost = append(lost, t)
+		}
+	}
+	return lost
+}
+
+func (m *Manager) purgeTasks(tasks []*task.Task) {
+	for _, t := range tasks {
+		if !t.DesiredStop || (t.State != task.Completed && t.State != task.Failed) {
+			continue
+		}
+		if time.Since(t.FinishTime) < m.PurgeAfter {
+			continue
+		}
+		if err := m.Store.PurgeTask(t.ID.String()); err != nil {
+			log.Printf("Error purging task %s: %v", t.ID, err)
 		}
-	} else {
-		log.Printf("Node %s is not leader, cannot update task %s state to %v", m.LocalID, t.ID, t.State)
 	}
 }
 
+func containerRef(t *task.Task) string {
+	if t.ContainerID != "" {
+		return t.ContainerID
+	}
+	return t.Name
+}
+
 func (m *Manager) scheduleTasks(tasks []*task.Task) {
+	var pending []*task.Task
 	for _, t := range tasks {
-		if t.State == task.Pending {
-			members := m.Cluster.Members()
-			var nodes []scheduler.Node
-			for _, member := range members {
-				var meta cluster.NodeMeta
-				if err := json.Unmarshal(member.Meta, &meta); err != nil {
-					log.Printf("Failed to unmarshal node meta for %s: %v", member.Name, err)
-					continue
-				}
-
-				nodes = append(nodes, scheduler.Node{
-					ID:          member.Name,
-					MemoryTotal: meta.MemoryTotal,
-					MemoryUsed:  meta.MemoryUsed,
-					DiskTotal:   100 * 1024 * 1024 * 1024,
-					DiskUsed:    0,
-					Tags:        map[string]string{"role": meta.Role},
-				})
-			}
+		if (t.State == task.Pending || t.State == task.Lost) && !t.DesiredStop {
+			pending = append(pending, t)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].StartTime.Before(pending[j].StartTime)
+	})
 
-			candidate := m.Scheduler.SelectCandidate(*t, nodes)
-			if candidate != nil {
-				t.NodeID = candidate.ID
-				t.State = task.Scheduled
-
-				event := task.TaskEvent{
-					ID:        t.ID,
-					State:     task.Scheduled,
-					Timestamp: time.Now(),
-					Task:      *t,
-				}
-
-				data, err := json.Marshal(event)
-				if err != nil {
-					log.Printf("Error marshaling event: %v", err)
-					continue
-				}
-
-				if future := m.Store.R.Apply(data, 10*time.Second); future.Error() != nil {
-					log.Printf("Error applying to Raft: %v", future.Error())
-				}
-			}
+	m.refreshScheduler()
+	nodes := buildNodes(m.Cluster.Members(), tasks)
+
+	for _, t := range pending {
+		candidate, rejections := m.Scheduler.Schedule(*t, nodes)
+		if candidate == nil {
+			m.recordUnschedulable(t, len(nodes), rejections)
+			continue
 		}
+
+		scheduled := *t
+		scheduled.NodeID = candidate.ID
+		scheduled.State = task.Scheduled
+		scheduled.Unschedulable = nil
+
+		event := task.TaskEvent{
+			ID:        t.ID,
+			State:     task.Scheduled,
+			Timestamp: time.Now(),
+			Task:      scheduled,
+		}
+
+		if err := m.Store.ApplyCommand(store.CommandTaskEvent, event); err != nil {
+			log.Printf("Error applying to Raft: %v", err)
+			continue
+		}
+		candidate.Reserve(*t)
+	}
+}
+
+func (m *Manager) recordUnschedulable(t *task.Task, total int, rejections []task.NodeRejection) {
+	report := task.SchedulingReport{
+		Time:       time.Now(),
+		Message:    scheduler.Summarize(total, rejections),
+		Rejections: rejections,
+	}
+	if t.Unschedulable.SameRejections(&report) {
+		return
+	}
+
+	log.Printf("Task %s is unschedulable: %s", t.ID, report.Message)
+	if err := m.Store.ApplyCommand(store.CommandTaskUnschedulable, store.TaskUnschedulable{ID: t.ID, Report: report}); err != nil {
+		log.Printf("Error recording scheduling report for task %s: %v", t.ID, err)
+	}
+}
+
+func (m *Manager) refreshScheduler() {
+	raw, ok := m.Store.GetConfig(scheduler.ConfigKey)
+	if !ok || bytes.Equal(raw, m.schedulerConfig) {
+		return
 	}
+	m.schedulerConfig = raw
+
+	cfg, err := scheduler.ParseConfig(raw)
+	if err != nil {
+		log.Printf("Ignoring scheduler config: %v", err)
+		return
+	}
+	sched, err := scheduler.NewWithConfig(cfg)
+	if err != nil {
+		log.Printf("Ignoring scheduler config: %v", err)
+		return
+	}
+	log.Printf("Scheduler reconfigured from cluster config")
+	m.Scheduler = sched
+}
+
+func buildNodes(members []*memberlist.Node, tasks []*task.Task) []scheduler.Node {
+	var nodes []scheduler.Node
+	index := make(map[string]int)
+	for _, member := range members {
+		var meta cluster.NodeMeta
+		if err := json.Unmarshal(member.Meta, &meta); err != nil {
+			log.Printf("Failed to unmarshal node meta for %s: %v", member.Name, err)
+			continue
+		}
+
+		index[member.Name] = len(nodes)
+		nodes = append(nodes, scheduler.Node{
+			ID:          member.Name,
+			MemoryTotal: meta.MemoryTotal,
+			MemoryUsed:  meta.MemoryUsed,
+			DiskTotal:   meta.DiskTotal,
+			DiskUsed:    meta.DiskUsed,
+			CpuTotal:    meta.CpuTotal,
+			Load:        meta.Load1,
+			Tags:        map[string]string{"role": meta.Role},
+		})
+	}
+
+	for _, t := range tasks {
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+		i, ok := index[t.NodeID]
+		if !ok {
+			continue
+		}
+		r := *t
+		if t.State == task.Running {
+			// the gossiped MemoryUsed already includes what a running
+			// container uses; only tasks still starting need reserving
+			r.Memory = 0
+		}
+		nodes[i].Reserve(r)
+	}
+
+	return nodes
 }
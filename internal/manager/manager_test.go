@@ -2,9 +2,15 @@ package manager
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/bit2swaz/orion/internal/cluster"
+	"github.com/bit2swaz/orion/internal/service"
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
 	"github.com/hashicorp/memberlist"
 )
 
@@ -32,3 +38,463 @@ func TestManager_Struct_Compile(t *testing.T) {
 		Cluster: &cluster.Manager{},
 	}
 }
+
+func TestBuildNodes_SubtractsPlacedTasks(t *testing.T) {
+	// MockCluster gossips 1GiB of measured memory use, which already
+	// covers the running task.
+	members := (&MockCluster{}).Members()
+	tasks := []*task.Task{
+		{ID: uuid.New(), NodeID: "worker-1", State: task.Running, Memory: 512, Disk: 10},
+		{ID: uuid.New(), NodeID: "worker-1", State: task.Scheduled, Memory: 256},
+		{ID: uuid.New(), NodeID: "worker-1", State: task.Completed, Memory: 1024},
+		{ID: uuid.New(), State: task.Pending, Memory: 2048},
+	}
+
+	nodes := buildNodes(members, tasks)
+	if len(nodes) != 1 {
+		t.Fatalf("Expected 1 node, got %d", len(nodes))
+	}
+	if nodes[0].MemoryAllocated != 256 {
+		t.Errorf("Expected only the starting task's 256 bytes allocated, got %d", nodes[0].MemoryAllocated)
+	}
+	if nodes[0].DiskAllocated != 10 {
+		t.Errorf("Expected 10 bytes of disk allocated, got %d", nodes[0].DiskAllocated)
+	}
+}
+
+func TestFindLostTasks_GracePeriod(t *testing.T) {
+	m := &Manager{
+		NodeGracePeriod: 30 * time.Second,
+		missingSince:    make(map[string]time.Time),
+	}
+	members := (&MockCluster{}).Members()
+	onDead := &task.Task{ID: uuid.New(), NodeID: "worker-2", State: task.Running}
+	tasks := []*task.Task{
+		{ID: uuid.New(), NodeID: "worker-1", State: task.Running},
+		onDead,
+		{ID: uuid.New(), NodeID: "worker-2", State: task.Completed},
+		{ID: uuid.New(), State: task.Pending},
+	}
+
+	start := time.Now()
+	if lost := m.findLostTasks(tasks, members, start); len(lost) != 0 {
+		t.Fatalf("Expected no lost tasks on first sighting, got %d", len(lost))
+	}
+	if lost := m.findLostTasks(tasks, members, start.Add(10*time.Second)); len(lost) != 0 {
+		t.Fatalf("Expected no lost tasks inside grace period, got %d", len(lost))
+	}
+
+	lost := m.findLostTasks(tasks, members, start.Add(31*time.Second))
+	if len(lost) != 1 || lost[0].ID != onDead.ID {
+		t.Fatalf("Expected only the running task on worker-2 to be lost, got %v", lost)
+	}
+
+	rejoined := append(members, &memberlist.Node{Name: "worker-2"})
+	if lost := m.findLostTasks(tasks, rejoined, start.Add(40*time.Second)); len(lost) != 0 {
+		t.Errorf("Expected no lost tasks after node rejoined, got %d", len(lost))
+	}
+	if _, ok := m.missingSince["worker-2"]; ok {
+		t.Errorf("Expected grace timer to reset when node rejoined")
+	}
+}
+
+func openStore(t *testing.T) *store.Store {
+	s := store.New()
+	if err := s.Open(t.TempDir(), "node-1", "127.0.0.1:0", true); err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.R.Shutdown().Error() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.IsLeader() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Node did not become leader after bootstrap")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return s
+}
+
+func TestReconcileServices_ConvergesReplicas(t *testing.T) {
+	s := openStore(t)
+	m := &Manager{Store: s}
+
+	if _, err := s.CreateService(service.Service{Name: "web", Replicas: 3, Template: task.Task{Image: "nginx"}}); err != nil {
+		t.Fatalf("CreateService failed: %v", err)
+	}
+
+	liveTasks := func() []*task.Task {
+		tasks, _ := s.ListTasks()
+		var live []*task.Task
+		for _, tk := range tasks {
+			if tk.ServiceName == "web" && service.Live(tk) {
+				live = append(live, tk)
+			}
+		}
+		return live
+	}
+	reconcile := func() {
+		tasks, _ := s.ListTasks()
+		m.reconcileServices(tasks)
+	}
+
+	reconcile()
+	if live := liveTasks(); len(live) != 3 {
+		t.Fatalf("Expected 3 live replicas, got %d", len(live))
+	}
+
+	reconcile()
+	if live := liveTasks(); len(live) != 3 {
+		t.Fatalf("Expected reconcile to be idempotent, got %d live replicas", len(live))
+	}
+
+	failed := liveTasks()[0]
+	failedCopy := *failed
+	failedCopy.State = task.Failed
+	s.ApplyCommand(store.CommandTaskEvent, task.TaskEvent{ID: failed.ID, State: task.Failed, Task: failedCopy})
+	reconcile()
+	if live := liveTasks(); len(live) != 3 {
+		t.Fatalf("Expected failed replica to be replaced, got %d live replicas", len(live))
+	}
+	if stored, _ := s.GetTask(failed.ID.String()); !stored.DesiredStop {
+		t.Errorf("Expected failed replica to be retired")
+	}
+
+	if _, err := s.UpdateService(service.Service{Name: "web", Replicas: 1, Template: task.Task{Image: "nginx"}}); err != nil {
+		t.Fatalf("UpdateService failed: %v", err)
+	}
+	reconcile()
+	if live := liveTasks(); len(live) != 1 {
+		t.Fatalf("Expected scale down to 1 replica, got %d", len(live))
+	}
+
+	if err := s.DeleteService("web"); err != nil {
+		t.Fatalf("DeleteService failed: %v", err)
+	}
+	reconcile()
+	if live := liveTasks(); len(live) != 0 {
+		t.Errorf("Expected orphaned replicas to be stopped, got %d", len(live))
+	}
+}
+
+func TestSortForScaleDown(t *testing.T) {
+	now := time.Now()
+	running := &task.Task{ID: uuid.New(), State: task.Running, StartTime: now.Add(-time.Hour)}
+	newer := &task.Task{ID: uuid.New(), State: task.Running, StartTime: now}
+	scheduled := &task.Task{ID: uuid.New(), State: task.Scheduled, StartTime: now.Add(-2 * time.Hour)}
+	pending := &task.Task{ID: uuid.New(), State: task.Pending, StartTime: now.Add(-3 * time.Hour)}
+
+	tasks := []*task.Task{running, newer, scheduled, pending}
+	sortForScaleDown(tasks)
+
+	want := []*task.Task{pending, scheduled, newer, running}
+	for i := range want {
+		if tasks[i] != want[i] {
+			t.Errorf("Position %d: expected %s task, got %s", i, want[i].State, tasks[i].State)
+		}
+	}
+}
+
+// serviceFixture drives the service controller against a single node store
+// without running any containers.
+type serviceFixture struct {
+	t *testing.T
+	s *store.Store
+	m *Manager
+}
+
+func newServiceFixture(t *testing.T, svc service.Service) *serviceFixture {
+	s := openStore(t)
+	if _, err := s.CreateService(svc); err != nil {
+		t.Fatalf("CreateService failed: %v", err)
+	}
+	return &serviceFixture{t: t, s: s, m: &Manager{Store: s}}
+}
+
+func (f *serviceFixture) reconcile() {
+	tasks, _ := f.s.ListTasks()
+	f.m.reconcileServices(tasks)
+}
+
+func (f *serviceFixture) byVersion(version int) (live, ready []*task.Task) {
+	tasks, _ := f.s.ListTasks()
+	for _, tk := range tasks {
+		if tk.ServiceVersion == version && service.Live(tk) {
+			live = append(live, tk)
+			if service.Ready(tk) {
+				ready = append(ready, tk)
+			}
+		}
+	}
+	return live, ready
+}
+
+func (f *serviceFixture) setState(tasks []*task.Task, state task.State) {
+	for _, tk := range tasks {
+		updated := *tk
+		updated.State = state
+		event := task.TaskEvent{ID: tk.ID, State: state, Timestamp: time.Now(), Task: updated}
+		if err := f.s.ApplyCommand(store.CommandTaskEvent, event); err != nil {
+			f.t.Fatalf("Failed to set task state: %v", err)
+		}
+	}
+}
+
+func (f *serviceFixture) setHealth(tasks []*task.Task, status task.HealthStatus) {
+	for _, tk := range tasks {
+		updated := *tk
+		updated.Health = &task.HealthReport{Time: time.Now(), Status: status}
+		event := task.TaskEvent{ID: tk.ID, State: tk.State, Timestamp: time.Now(), Task: updated}
+		if err := f.s.ApplyCommand(store.CommandTaskEvent, event); err != nil {
+			f.t.Fatalf("Failed to set task health: %v", err)
+		}
+	}
+}
+
+func (f *serviceFixture) service() *service.Service {
+	svc, _ := f.s.GetService("web")
+	return svc
+}
+
+func (f *serviceFixture) update(svc service.Service) {
+	if _, err := f.s.UpdateService(svc); err != nil {
+		f.t.Fatalf("UpdateService failed: %v", err)
+	}
+}
+
+// start brings version 1 of the service up with every replica running.
+func (f *serviceFixture) start() {
+	f.reconcile()
+	v1, _ := f.byVersion(1)
+	f.setState(v1, task.Running)
+}
+
+func TestReconcileServices_RollingUpdate(t *testing.T) {
+	template := task.Task{Image: "nginx:1.26"}
+	f := newServiceFixture(t, service.Service{Name: "web", Replicas: 3, Template: template})
+	f.start()
+
+	template.Image = "nginx:1.27"
+	f.update(service.Service{Name: "web", Replicas: 3, Template: template})
+	if st := f.service().Status.State; st != service.UpdateRolling {
+		t.Fatalf("Expected template change to start an update, got %q", st)
+	}
+
+	// Default limits surge one replica and keep all three old ones until it is ready.
+	f.reconcile()
+	f.reconcile()
+	v2, _ := f.byVersion(2)
+	v1, _ := f.byVersion(1)
+	if len(v2) != 1 || len(v1) != 3 {
+		t.Fatalf("Expected 1 new and 3 old replicas, got %d and %d", len(v2), len(v1))
+	}
+
+	f.setState(v2, task.Failed)
+	f.reconcile()
+	if st := f.service().Status.State; st != service.UpdateHalted {
+		t.Fatalf("Expected failed replica to halt the update, got %q", st)
+	}
+	f.reconcile()
+	if v2, _ = f.byVersion(2); len(v2) != 0 {
+		t.Fatalf("Expected halted update to start no replicas, got %d", len(v2))
+	}
+
+	if err := f.s.SetServiceStatus("web", 2, service.UpdateStatus{State: service.UpdateRolling, StartTime: time.Now()}); err != nil {
+		t.Fatalf("SetServiceStatus failed: %v", err)
+	}
+
+	for i := 0; i < 10 && f.service().Status.State == service.UpdateRolling; i++ {
+		f.reconcile()
+		v2, _ = f.byVersion(2)
+		f.setState(v2, task.Running)
+
+		live1, ready1 := f.byVersion(1)
+		_, ready2 := f.byVersion(2)
+		if len(ready1)+len(ready2) < 3 {
+			t.Fatalf("Availability dropped below replicas: %d ready", len(ready1)+len(ready2))
+		}
+		if len(live1)+len(v2) > 4 {
+			t.Fatalf("Surge exceeded: %d replicas", len(live1)+len(v2))
+		}
+	}
+
+	if st := f.service().Status.State; st != service.UpdateCompleted {
+		t.Fatalf("Expected update to complete, got %q", st)
+	}
+	if v1, _ = f.byVersion(1); len(v1) != 0 {
+		t.Errorf("Expected all old replicas to be replaced, %d left", len(v1))
+	}
+	if _, ready := f.byVersion(2); len(ready) != 3 {
+		t.Errorf("Expected 3 ready new replicas, got %d", len(ready))
+	}
+}
+
+func TestReconcileServices_HealthGatedUpdate(t *testing.T) {
+	template := task.Task{Image: "nginx:1.26", HealthCheck: &task.HealthCheck{Type: task.CheckHTTP, Path: "/", Port: 80}}
+	f := newServiceFixture(t, service.Service{Name: "web", Replicas: 2, Template: template})
+	f.start()
+	v1, _ := f.byVersion(1)
+	f.setHealth(v1, task.Healthy)
+
+	template.Image = "nginx:1.27"
+	f.update(service.Service{Name: "web", Replicas: 2, Template: template})
+	f.reconcile()
+	v2, _ := f.byVersion(2)
+	f.setState(v2, task.Running)
+
+	// Running but not yet healthy: the update must not retire old replicas.
+	f.reconcile()
+	f.reconcile()
+	if v1, _ = f.byVersion(1); len(v1) != 2 {
+		t.Fatalf("Expected old replicas to wait for a healthy new one, %d left", len(v1))
+	}
+
+	v2, _ = f.byVersion(2)
+	f.setHealth(v2, task.Unhealthy)
+	f.reconcile()
+	if st := f.service().Status; st.State != service.UpdateHalted || !strings.Contains(st.Message, "unhealthy") {
+		t.Fatalf("Expected unhealthy replica to halt the update, got %+v", st)
+	}
+}
+
+func TestReconcileServices_Canary(t *testing.T) {
+	update := service.UpdateConfig{Strategy: service.StrategyCanary, Canaries: 2}
+	f := newServiceFixture(t, service.Service{Name: "web", Replicas: 4, Update: update, Template: task.Task{Image: "nginx:1.26"}})
+	f.start()
+
+	f.update(service.Service{Name: "web", Replicas: 4, Update: update, Template: task.Task{Image: "nginx:1.27"}})
+	for i := 0; i < 3; i++ {
+		f.reconcile()
+		v2, _ := f.byVersion(2)
+		f.setState(v2, task.Running)
+	}
+
+	if v1, _ := f.byVersion(1); len(v1) != 4 {
+		t.Errorf("Expected old replicas to stay until promotion, got %d", len(v1))
+	}
+	if _, ready := f.byVersion(2); len(ready) != 2 {
+		t.Fatalf("Expected 2 ready canaries, got %d", len(ready))
+	}
+	if msg := f.service().Status.Message; msg != "2/2 canaries ready, awaiting promotion" {
+		t.Errorf("Unexpected status message %q", msg)
+	}
+
+	status := f.service().Status
+	status.Promoted = true
+	f.s.SetServiceStatus("web", 2, status)
+	for i := 0; i < 10 && f.service().Status.State == service.UpdateRolling; i++ {
+		f.reconcile()
+		v2, _ := f.byVersion(2)
+		f.setState(v2, task.Running)
+	}
+
+	if st := f.service().Status.State; st != service.UpdateCompleted {
+		t.Fatalf("Expected promoted canary to complete, got %q", st)
+	}
+	if v1, _ := f.byVersion(1); len(v1) != 0 {
+		t.Errorf("Expected old replicas to be replaced, %d left", len(v1))
+	}
+}
+
+func TestReconcileServices_CanaryAbort(t *testing.T) {
+	update := service.UpdateConfig{Strategy: service.StrategyCanary}
+	f := newServiceFixture(t, service.Service{Name: "web", Replicas: 3, Update: update, Template: task.Task{Image: "nginx:1.26"}})
+	f.start()
+
+	f.update(service.Service{Name: "web", Replicas: 3, Update: update, Template: task.Task{Image: "nginx:1.27"}})
+	f.reconcile()
+	v2, _ := f.byVersion(2)
+	f.setState(v2, task.Running)
+
+	if _, err := f.s.AbortServiceUpdate("web"); err != nil {
+		t.Fatalf("AbortServiceUpdate failed: %v", err)
+	}
+	f.reconcile()
+	f.reconcile()
+
+	if st := f.service().Status.State; st != service.UpdateCompleted {
+		t.Errorf("Expected abort to complete straight away, got %q", st)
+	}
+	if v2, _ := f.byVersion(2); len(v2) != 0 {
+		t.Errorf("Expected canary to be removed, got %d", len(v2))
+	}
+	if _, ready := f.byVersion(1); len(ready) != 3 {
+		t.Errorf("Expected the original replicas to be kept, got %d", len(ready))
+	}
+	if v3, _ := f.byVersion(3); len(v3) != 0 {
+		t.Errorf("Expected no replicas to be recreated for the restored revision, got %d", len(v3))
+	}
+}
+
+func TestReconcileServices_BlueGreen(t *testing.T) {
+	update := service.UpdateConfig{Strategy: service.StrategyBlueGreen}
+	f := newServiceFixture(t, service.Service{Name: "web", Replicas: 3, Update: update, Template: task.Task{Image: "nginx:1.26"}})
+	f.start()
+
+	f.update(service.Service{Name: "web", Replicas: 3, Update: update, Template: task.Task{Image: "nginx:1.27"}})
+	f.reconcile()
+	v2, _ := f.byVersion(2)
+	if len(v2) != 3 {
+		t.Fatalf("Expected a full green set of 3, got %d", len(v2))
+	}
+
+	f.setState(v2[:2], task.Running)
+	f.reconcile()
+	if v1, _ := f.byVersion(1); len(v1) != 3 {
+		t.Fatalf("Expected blue set to stay until green is ready, got %d", len(v1))
+	}
+
+	f.setState(v2[2:], task.Running)
+	f.reconcile()
+	if v1, _ := f.byVersion(1); len(v1) != 0 {
+		t.Errorf("Expected blue set to be retired, %d left", len(v1))
+	}
+	f.reconcile()
+	if st := f.service().Status.State; st != service.UpdateCompleted {
+		t.Errorf("Expected update to complete, got %q", st)
+	}
+}
+
+func TestRestartTasks_Backoff(t *testing.T) {
+	s := openStore(t)
+	m := &Manager{Store: s}
+
+	recent := task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Failed, RestartPolicy: task.RestartAlways, RestartCount: 3, FinishTime: time.Now()}
+	due := task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Failed, RestartPolicy: task.RestartAlways, FinishTime: time.Now().Add(-time.Minute)}
+	done := task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Completed, RestartPolicy: task.RestartOnFailure}
+	for _, tk := range []task.Task{recent, due, done} {
+		if err := s.ApplyCommand(store.CommandTaskEvent, task.TaskEvent{ID: tk.ID, State: tk.State, Task: tk}); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+	}
+
+	tasks, _ := s.ListTasks()
+	m.restartTasks(tasks)
+
+	if got, _ := s.GetTask(due.ID.String()); got.State != task.Scheduled || got.RestartCount != 1 {
+		t.Errorf("Expected task past its backoff to restart, got %v (%d restarts)", got.State, got.RestartCount)
+	}
+	if got, _ := s.GetTask(recent.ID.String()); got.State != task.Failed {
+		t.Errorf("Expected task within its backoff to wait, got %v", got.State)
+	}
+	if got, _ := s.GetTask(done.ID.String()); got.State != task.Completed {
+		t.Errorf("Expected successful on-failure task to stay completed, got %v", got.State)
+	}
+
+	wantWake := recent.FinishTime.Add(8 * time.Second)
+	if m.nextWake.Sub(wantWake).Abs() > time.Second {
+		t.Errorf("Expected a wake-up at the end of the backoff (%v), got %v", wantWake, m.nextWake)
+	}
+}
+
+func TestSetDefaults(t *testing.T) {
+	m := &Manager{ResyncInterval: -time.Second, ExecConcurrency: -1, NodeGracePeriod: -time.Second, PurgeAfter: time.Minute}
+	m.setDefaults()
+
+	if m.ResyncInterval != DefaultResyncInterval || m.ExecConcurrency != DefaultExecConcurrency || m.NodeGracePeriod != DefaultNodeGracePeriod {
+		t.Errorf("Expected invalid settings to fall back to defaults, got %+v", m)
+	}
+	if m.ExecTimeout != DefaultExecTimeout || m.StopTimeout != DefaultStopTimeout {
+		t.Errorf("Expected unset timeouts to fall back to defaults, got %s and %s", m.ExecTimeout, m.StopTimeout)
+	}
+}
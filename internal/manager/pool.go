@@ -0,0 +1,150 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+const (
+	opExec = "exec"
+	opStop = "stop"
+)
+
+// settleTimeout bounds how long a finished start keeps its task marked in
+// flight while waiting for the reported state to reach the local store.
+const settleTimeout = 15 * time.Second
+
+// execPool runs container operations off the reconcile loop, at most limit
+// at a time and at most one per task.
+type execPool struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+
+	mu       sync.Mutex
+	inflight map[uuid.UUID]*operation
+}
+
+type operation struct {
+	name   string
+	cancel context.CancelFunc
+
+	// set by settle: the task as the operation saw it, and when to stop
+	// waiting for the store to move past it
+	seen  *task.Task
+	until time.Time
+	done  bool
+}
+
+func newExecPool(limit int) *execPool {
+	return &execPool{
+		sem:      make(chan struct{}, max(limit, 1)),
+		inflight: make(map[uuid.UUID]*operation),
+	}
+}
+
+// start runs fn in the background with a context cancelled after timeout,
+// unless an operation for id is already queued or running.
+func (p *execPool) start(id uuid.UUID, name string, timeout time.Duration, fn func(ctx context.Context)) bool {
+	p.mu.Lock()
+	if op, ok := p.inflight[id]; ok && !(op.done && time.Now().After(op.until)) {
+		p.mu.Unlock()
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	op := &operation{name: name, cancel: cancel}
+	p.inflight[id] = op
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer p.finish(id, op)
+
+		select {
+		case p.sem <- struct{}{}:
+			defer func() { <-p.sem }()
+		case <-ctx.Done():
+			return
+		}
+		fn(ctx)
+	}()
+	return true
+}
+
+func (p *execPool) finish(id uuid.UUID, op *operation) {
+	op.cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inflight[id] != op {
+		return
+	}
+	if op.seen != nil {
+		op.done = true
+		return
+	}
+	delete(p.inflight, id)
+}
+
+// settle keeps id in flight once the running operation returns, until
+// observe sees a version of the task other than t or timeout passes. The
+// store replaces a task on every change, so a new pointer means the
+// operation's report has been applied locally.
+func (p *execPool) settle(id uuid.UUID, t *task.Task, timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if op, ok := p.inflight[id]; ok {
+		op.seen = t
+		op.until = time.Now().Add(timeout)
+	}
+}
+
+// observe releases a settled operation for id once the store holds a newer
+// version of the task than the one it acted on.
+func (p *execPool) observe(id uuid.UUID, t *task.Task) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	op, ok := p.inflight[id]
+	if ok && op.done && (op.seen != t || time.Now().After(op.until)) {
+		delete(p.inflight, id)
+	}
+}
+
+// cancel cancels the in-flight operation for id if it is named name.
+func (p *execPool) cancel(id uuid.UUID, name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	op, ok := p.inflight[id]
+	if !ok || op.name != name {
+		return false
+	}
+	if op.done {
+		// nothing left to cancel; just stop waiting for the store
+		delete(p.inflight, id)
+		return false
+	}
+	op.cancel()
+	return true
+}
+
+func (p *execPool) ids() []uuid.UUID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ids := make([]uuid.UUID, 0, len(p.inflight))
+	for id := range p.inflight {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (p *execPool) wait() {
+	p.wg.Wait()
+}
@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+	"github.com/hashicorp/raft"
+)
+
+func TestExecPool_LimitsConcurrency(t *testing.T) {
+	p := newExecPool(2)
+	release := make(chan struct{})
+	var running, peak atomic.Int32
+
+	for i := 0; i < 5; i++ {
+		p.start(uuid.New(), opExec, time.Minute, func(ctx context.Context) {
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	p.wait()
+
+	if got := peak.Load(); got != 2 {
+		t.Errorf("Expected at most 2 concurrent operations, got %d", got)
+	}
+	if ids := p.ids(); len(ids) != 0 {
+		t.Errorf("Expected no operations in flight, got %d", len(ids))
+	}
+}
+
+func TestExecPool_OnePerTask(t *testing.T) {
+	p := newExecPool(4)
+	id := uuid.New()
+	release := make(chan struct{})
+	var calls atomic.Int32
+
+	fn := func(ctx context.Context) {
+		calls.Add(1)
+		<-release
+	}
+	if !p.start(id, opExec, time.Minute, fn) {
+		t.Fatalf("Expected first start to be accepted")
+	}
+	if p.start(id, opExec, time.Minute, fn) || p.start(id, opStop, time.Minute, fn) {
+		t.Errorf("Expected a task with an operation in flight to be skipped")
+	}
+	close(release)
+	p.wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected one call, got %d", calls.Load())
+	}
+	if !p.start(id, opStop, time.Minute, func(ctx context.Context) {}) {
+		t.Errorf("Expected a new operation once the first finished")
+	}
+	p.wait()
+}
+
+func TestExecPool_CancelAndTimeout(t *testing.T) {
+	p := newExecPool(1)
+	id := uuid.New()
+	errs := make(chan error, 2)
+
+	p.start(id, opExec, time.Minute, func(ctx context.Context) {
+		<-ctx.Done()
+		errs <- ctx.Err()
+	})
+	time.Sleep(10 * time.Millisecond)
+
+	if p.cancel(id, opStop) {
+		t.Errorf("Expected cancel of a different operation to be ignored")
+	}
+	if !p.cancel(id, opExec) {
+		t.Fatalf("Expected in-flight exec to be cancelled")
+	}
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	p.wait()
+
+	p.start(uuid.New(), opExec, 20*time.Millisecond, func(ctx context.Context) {
+		<-ctx.Done()
+		errs <- ctx.Err()
+	})
+	if err := <-errs; err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	p.wait()
+}
+
+func TestExecPool_SettlesUntilStoreCatchesUp(t *testing.T) {
+	s := store.New()
+	id := uuid.New()
+	apply := func(state task.State) {
+		data, _ := store.NewCommand(store.CommandTaskEvent, task.TaskEvent{ID: id, State: state, Task: task.Task{ID: id, NodeID: "node-1", State: state}})
+		s.Apply(&raft.Log{Data: data})
+	}
+	apply(task.Scheduled)
+
+	p := newExecPool(2)
+	var starts atomic.Int32
+	applied := make(chan struct{})
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		cur, _ := s.GetTask(id.String())
+		p.observe(id, cur)
+		if cur.State == task.Running {
+			break
+		}
+		p.start(id, opExec, time.Minute, func(ctx context.Context) {
+			p.settle(id, cur, time.Minute)
+			starts.Add(1)
+			// the report reaches this node's FSM well after the start returns
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				apply(task.Running)
+				close(applied)
+			}()
+		})
+	}
+	<-applied
+	p.wait()
+
+	if got := starts.Load(); got != 1 {
+		t.Errorf("Expected the task to be started once, got %d", got)
+	}
+	cur, _ := s.GetTask(id.String())
+	p.observe(id, cur)
+	if ids := p.ids(); len(ids) != 0 {
+		t.Errorf("Expected the task released once the store moved on, got %d in flight", len(ids))
+	}
+
+	other := uuid.New()
+	seen := &task.Task{ID: other}
+	p.start(other, opExec, time.Minute, func(ctx context.Context) { p.settle(other, seen, 20*time.Millisecond) })
+	p.wait()
+	if p.start(other, opExec, time.Minute, func(ctx context.Context) {}) {
+		t.Errorf("Expected a settling task to be skipped")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if !p.start(other, opExec, time.Minute, func(ctx context.Context) {}) {
+		t.Errorf("Expected a new start once the settle timeout passed")
+	}
+	p.wait()
+}
@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+var reportClient = &http.Client{Timeout: 10 * time.Second}
+
+func (m *Manager) reportEvent(event task.TaskEvent) error {
+	if m.Store.IsLeader() {
+		return m.Store.ReportTaskEvent(event)
+	}
+
+	addr, err := m.Cluster.LeaderAPIAddr()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := reportClient.Post(fmt.Sprintf("http://%s/internal/events", addr), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("leader %s rejected event: %s: %s", addr, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"log"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+// restartTasks restarts finished tasks whose restart policy asks for it once
+// their backoff has passed.
+func (m *Manager) restartTasks(tasks []*task.Task) {
+	now := time.Now()
+	for _, t := range tasks {
+		if !t.WillRestart() {
+			continue
+		}
+		if at := t.FinishTime.Add(t.RestartDelay()); now.Before(at) {
+			m.wakeAt(at)
+			continue
+		}
+
+		log.Printf("Restarting task %s (restart %d, exit: %s)", t.ID, t.RestartCount+1, exitReason(t))
+		if err := m.Store.ApplyCommand(store.CommandTaskRestart, store.TaskRef{ID: t.ID, Timestamp: now}); err != nil {
+			log.Printf("Error restarting task %s: %v", t.ID, err)
+		}
+	}
+}
+
+func exitReason(t *task.Task) string {
+	if t.Exit != nil {
+		return t.Exit.Reason
+	}
+	return t.State.String()
+}
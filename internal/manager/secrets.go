@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+// resolveSecrets decrypts the secrets t refers to. This only happens on the
+// node about to start t.
+func (m *Manager) resolveSecrets(t *task.Task) (map[string][]byte, error) {
+	if len(t.Secrets) == 0 {
+		return nil, nil
+	}
+	if m.SecretKey == nil {
+		return nil, fmt.Errorf("task uses secrets but this node has no cluster key")
+	}
+
+	values := make(map[string][]byte, len(t.Secrets))
+	for _, ref := range t.Secrets {
+		sec, err := m.Store.GetSecret(ref.Name)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %v", ref.Name, err)
+		}
+		value, err := m.SecretKey.Open(sec.Name, sec.Data)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %v", ref.Name, err)
+		}
+		values[ref.Name] = value
+	}
+	return values, nil
+}
+
+// pruneSecrets removes the secret files of tasks no longer starting or
+// running on this node.
+func (m *Manager) pruneSecrets(tasks []*task.Task) {
+	if m.Worker == nil {
+		return
+	}
+	keep := make(map[uuid.UUID]bool)
+	for _, t := range tasks {
+		if t.NodeID == m.LocalID && (t.State == task.Scheduled || t.State == task.Running) {
+			keep[t.ID] = true
+		}
+	}
+	if err := m.Worker.PruneSecrets(keep); err != nil {
+		log.Printf("Error pruning secret files: %v", err)
+	}
+}
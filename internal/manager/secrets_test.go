@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/secret"
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	s := openStore(t)
+	key, _ := secret.NewKey(bytes.Repeat([]byte{7}, secret.KeySize))
+	sealed, _ := key.Seal("db-password", []byte("hunter2"))
+	if _, err := s.PutSecret("db-password", sealed); err != nil {
+		t.Fatalf("PutSecret failed: %v", err)
+	}
+
+	tk := &task.Task{Secrets: []task.SecretRef{{Name: "db-password", Env: "DB_PASSWORD"}}}
+
+	m := &Manager{Store: s}
+	if _, err := m.resolveSecrets(tk); err == nil {
+		t.Error("Expected a node without a cluster key to fail")
+	}
+
+	m.SecretKey = key
+	values, err := m.resolveSecrets(tk)
+	if err != nil || string(values["db-password"]) != "hunter2" {
+		t.Fatalf("resolveSecrets() = %q, %v", values, err)
+	}
+
+	other, _ := secret.NewKey(bytes.Repeat([]byte{8}, secret.KeySize))
+	m.SecretKey = other
+	if _, err := m.resolveSecrets(tk); err == nil {
+		t.Error("Expected a different cluster key to fail")
+	}
+
+	tk.Secrets = append(tk.Secrets, task.SecretRef{Name: "missing", Env: "X"})
+	m.SecretKey = key
+	if _, err := m.resolveSecrets(tk); err == nil {
+		t.Error("Expected a missing secret to fail")
+	}
+}
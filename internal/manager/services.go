@@ -0,0 +1,283 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/service"
+	"github.com/bit2swaz/orion/internal/store"
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+func (m *Manager) reconcileServices(tasks []*task.Task) {
+	services, err := m.Store.ListServices()
+	if err != nil {
+		log.Printf("Error listing services: %v", err)
+		return
+	}
+
+	owned := make(map[string][]*task.Task)
+	for _, t := range tasks {
+		if t.ServiceName != "" {
+			owned[t.ServiceName] = append(owned[t.ServiceName], t)
+		}
+	}
+
+	for _, svc := range services {
+		m.convergeService(svc, owned[svc.Name])
+		delete(owned, svc.Name)
+	}
+
+	for name, orphans := range owned {
+		for _, t := range orphans {
+			if !t.DesiredStop {
+				log.Printf("Service %s deleted, stopping task %s", name, t.ID)
+				m.retireTask(t)
+			}
+		}
+	}
+}
+
+func (m *Manager) convergeService(svc *service.Service, owned []*task.Task) {
+	versions := m.currentVersions(svc)
+
+	var current, old []*task.Task
+	for _, t := range owned {
+		switch {
+		case service.Live(t) && versions[t.ServiceVersion]:
+			current = append(current, t)
+		case service.Live(t):
+			old = append(old, t)
+		case !t.DesiredStop:
+			m.retireTask(t)
+		}
+	}
+
+	if svc.Status.State == service.UpdateRolling {
+		if t := updateFailure(svc, versions, owned); t != nil {
+			msg := fmt.Sprintf("task %s failed on node %s", t.ID, t.NodeID)
+			if t.State != task.Failed {
+				msg = fmt.Sprintf("task %s unhealthy on node %s: %s", t.ID, t.NodeID, t.Health.Message)
+			}
+			log.Printf("Service %s: halting update to version %d: %s", svc.Name, svc.Version, msg)
+			m.setServiceStatus(svc, service.UpdateStatus{
+				State:     service.UpdateHalted,
+				Message:   msg,
+				StartTime: svc.Status.StartTime,
+			})
+			return
+		}
+	}
+
+	if len(old) == 0 {
+		m.scaleService(svc, current)
+		if svc.Status.State == service.UpdateRolling && countReady(current) >= svc.Replicas {
+			log.Printf("Service %s: update to version %d completed", svc.Name, svc.Version)
+			m.setServiceStatus(svc, service.UpdateStatus{
+				State:          service.UpdateCompleted,
+				StartTime:      svc.Status.StartTime,
+				CompletionTime: time.Now(),
+			})
+		}
+		return
+	}
+
+	// A paused or halted update leaves every replica where it is until it
+	// is resumed or the template changes again.
+	if svc.Status.State == service.UpdatePaused || svc.Status.State == service.UpdateHalted {
+		return
+	}
+
+	switch svc.Update.Strategy {
+	case service.StrategyCanary:
+		m.canaryService(svc, current, old)
+	case service.StrategyBlueGreen:
+		m.blueGreenService(svc, current, old)
+	default:
+		m.rollService(svc, current, old)
+	}
+}
+
+// currentVersions returns the versions of svc whose template matches the
+// current one, so replicas of an identical earlier revision, such as the one
+// a rollback returns to, are kept rather than replaced.
+func (m *Manager) currentVersions(svc *service.Service) map[int]bool {
+	versions := map[int]bool{svc.Version: true}
+	history, err := m.Store.ServiceHistory(svc.Name)
+	if err != nil {
+		return versions
+	}
+	for _, rev := range history {
+		if reflect.DeepEqual(rev.Template, svc.Template) {
+			versions[rev.Version] = true
+		}
+	}
+	return versions
+}
+
+func (m *Manager) scaleService(svc *service.Service, live []*task.Task) {
+	for i := len(live); i < svc.Replicas; i++ {
+		if err := m.createReplica(svc); err != nil {
+			log.Printf("Error creating replica for service %s: %v", svc.Name, err)
+			return
+		}
+	}
+
+	if extra := len(live) - svc.Replicas; extra > 0 {
+		sortForScaleDown(live)
+		for _, t := range live[:extra] {
+			log.Printf("Service %s: removing replica %s", svc.Name, t.ID)
+			m.retireTask(t)
+		}
+	}
+}
+
+// rollService moves one step towards replacing old with replicas of the
+// current version, starting at most maxSurge replicas above Replicas and
+// keeping at least Replicas-maxUnavailable of them ready.
+func (m *Manager) rollService(svc *service.Service, current, old []*task.Task) {
+	surge, unavailable := svc.Update.Limits()
+
+	create := min(svc.Replicas+surge-len(current)-len(old), svc.Replicas-len(current))
+	for i := 0; i < create; i++ {
+		if err := m.createReplica(svc); err != nil {
+			log.Printf("Error creating replica for service %s: %v", svc.Name, err)
+			break
+		}
+	}
+
+	ready := countReady(current) + countReady(old)
+	floor := svc.Replicas - unavailable
+	sortForScaleDown(old)
+	for _, t := range old {
+		if service.Ready(t) {
+			if ready-1 < floor {
+				break
+			}
+			ready--
+		}
+		log.Printf("Service %s: replacing replica %s (version %d)", svc.Name, t.ID, t.ServiceVersion)
+		m.retireTask(t)
+	}
+}
+
+// canaryService runs the canaries next to the old replicas until the update
+// is promoted, then continues as a rolling update.
+func (m *Manager) canaryService(svc *service.Service, current, old []*task.Task) {
+	if svc.Status.Promoted || countReady(current) >= svc.Replicas {
+		m.rollService(svc, current, old)
+		return
+	}
+
+	canaries := min(svc.Update.CanaryCount(), svc.Replicas)
+	for i := len(current); i < canaries; i++ {
+		if err := m.createReplica(svc); err != nil {
+			log.Printf("Error creating canary for service %s: %v", svc.Name, err)
+			return
+		}
+	}
+
+	msg := fmt.Sprintf("%d/%d canaries ready, awaiting promotion", min(countReady(current), canaries), canaries)
+	if msg != svc.Status.Message {
+		status := svc.Status
+		status.Message = msg
+		m.setServiceStatus(svc, status)
+	}
+}
+
+// blueGreenService starts a full set of new replicas and retires every old
+// one at once when all of them are ready.
+func (m *Manager) blueGreenService(svc *service.Service, current, old []*task.Task) {
+	for i := len(current); i < svc.Replicas; i++ {
+		if err := m.createReplica(svc); err != nil {
+			log.Printf("Error creating replica for service %s: %v", svc.Name, err)
+			return
+		}
+	}
+	if countReady(current) < svc.Replicas {
+		return
+	}
+
+	for _, t := range old {
+		log.Printf("Service %s: switching away from replica %s (version %d)", svc.Name, t.ID, t.ServiceVersion)
+		m.retireTask(t)
+	}
+}
+
+func (m *Manager) createReplica(svc *service.Service) error {
+	t := svc.NewTask(time.Now())
+	event := task.TaskEvent{
+		ID:        t.ID,
+		State:     task.Pending,
+		Timestamp: time.Now(),
+		Task:      t,
+	}
+	if err := m.Store.ApplyCommand(store.CommandTaskEvent, event); err != nil {
+		return err
+	}
+	log.Printf("Service %s: created replica %s (version %d)", svc.Name, t.ID, svc.Version)
+	return nil
+}
+
+func (m *Manager) setServiceStatus(svc *service.Service, status service.UpdateStatus) {
+	if err := m.Store.SetServiceStatus(svc.Name, svc.Version, status); err != nil {
+		log.Printf("Error updating status of service %s: %v", svc.Name, err)
+	}
+}
+
+// updateFailure returns a replica of the current version that failed or
+// turned unhealthy since the update started, if any.
+func updateFailure(svc *service.Service, versions map[int]bool, owned []*task.Task) *task.Task {
+	for _, t := range owned {
+		if !versions[t.ServiceVersion] {
+			continue
+		}
+		if t.State == task.Failed && !t.FinishTime.Before(svc.Status.StartTime) {
+			return t
+		}
+		if t.Health != nil && t.Health.Status == task.Unhealthy && !t.Health.Time.Before(svc.Status.StartTime) {
+			return t
+		}
+	}
+	return nil
+}
+
+func countReady(tasks []*task.Task) int {
+	n := 0
+	for _, t := range tasks {
+		if service.Ready(t) {
+			n++
+		}
+	}
+	return n
+}
+
+func (m *Manager) retireTask(t *task.Task) {
+	if _, err := m.Store.StopTask(t.ID.String()); err != nil {
+		log.Printf("Error stopping task %s: %v", t.ID, err)
+	}
+}
+
+// sortForScaleDown orders tasks so the cheapest to remove come first:
+// replicas that are not running yet, then the most recently started.
+func sortForScaleDown(tasks []*task.Task) {
+	rank := func(s task.State) int {
+		switch s {
+		case task.Pending, task.Lost:
+			return 0
+		case task.Scheduled:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		ri, rj := rank(tasks[i].State), rank(tasks[j].State)
+		if ri != rj {
+			return ri < rj
+		}
+		return tasks[i].StartTime.After(tasks[j].StartTime)
+	})
+}
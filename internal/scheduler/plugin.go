@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+// ConfigKey is the store config entry holding the cluster's plugin overrides.
+const ConfigKey = "scheduler"
+
+// MaxNodeScore is the top of the range every score plugin is normalised to
+// before weights are applied.
+const MaxNodeScore = 100
+
+type Plugin interface {
+	Name() string
+}
+
+// FilterPlugin rejects nodes that cannot run a task. The returned error is
+// the human readable reason for the rejection.
+type FilterPlugin interface {
+	Plugin
+	Filter(t task.Task, n *Node) error
+}
+
+// ScorePlugin ranks feasible nodes; higher is better. Raw scores only need
+// to be comparable within a single plugin.
+type ScorePlugin interface {
+	Plugin
+	Score(t task.Task, n *Node) float64
+}
+
+type Factory func(args json.RawMessage) (Plugin, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("scheduler: plugin %q registered twice", name))
+	}
+	registry[name] = factory
+}
+
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type PluginConfig struct {
+	Name     string          `json:"name"`
+	Disabled bool            `json:"disabled,omitempty"`
+	Weight   float64         `json:"weight,omitempty"`
+	Args     json.RawMessage `json:"args,omitempty"`
+}
+
+type Config struct {
+	Plugins []PluginConfig `json:"plugins"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Plugins: []PluginConfig{
+			{Name: "resource_fit"},
+			{Name: "node_selector"},
+			{Name: "host_ports"},
+			{Name: "avoid_nodes"},
+			{Name: "least_allocated"},
+			{Name: "spread", Disabled: true},
+			{Name: "load", Disabled: true},
+		},
+	}
+}
+
+// Merge overlays o onto c: entries naming an existing plugin replace it,
+// new names are appended.
+func (c Config) Merge(o Config) Config {
+	merged := Config{Plugins: append([]PluginConfig(nil), c.Plugins...)}
+	for _, p := range o.Plugins {
+		replaced := false
+		for i := range merged.Plugins {
+			if merged.Plugins[i].Name == p.Name {
+				merged.Plugins[i] = p
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Plugins = append(merged.Plugins, p)
+		}
+	}
+	return merged
+}
+
+func ParseConfig(b []byte) (Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return Config{}, fmt.Errorf("invalid scheduler config: %v", err)
+	}
+	return DefaultConfig().Merge(cfg), nil
+}
+
+type weightedScorer struct {
+	plugin ScorePlugin
+	weight float64
+}
+
+func build(cfg Config) ([]FilterPlugin, []weightedScorer, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var filters []FilterPlugin
+	var scorers []weightedScorer
+	for _, pc := range cfg.Plugins {
+		if pc.Disabled {
+			continue
+		}
+		factory, ok := registry[pc.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown scheduler plugin %q", pc.Name)
+		}
+		if pc.Weight < 0 {
+			return nil, nil, fmt.Errorf("scheduler plugin %q has negative weight %v", pc.Name, pc.Weight)
+		}
+
+		p, err := factory(pc.Args)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scheduler plugin %q: %v", pc.Name, err)
+		}
+
+		f, isFilter := p.(FilterPlugin)
+		sc, isScore := p.(ScorePlugin)
+		if !isFilter && !isScore {
+			return nil, nil, fmt.Errorf("scheduler plugin %q is neither a filter nor a score plugin", pc.Name)
+		}
+		if isFilter {
+			filters = append(filters, f)
+		}
+		if isScore {
+			weight := pc.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			scorers = append(scorers, weightedScorer{plugin: sc, weight: weight})
+		}
+	}
+	return filters, scorers, nil
+}
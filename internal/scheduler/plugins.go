@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+func init() {
+	Register("resource_fit", noArgs(resourceFit{}))
+	Register("node_selector", noArgs(nodeSelector{}))
+	Register("host_ports", noArgs(hostPorts{}))
+	Register("avoid_nodes", noArgs(avoidNodes{}))
+	Register("least_allocated", noArgs(leastAllocated{}))
+	Register("spread", noArgs(spread{}))
+	Register("load", noArgs(loadAverage{}))
+}
+
+func noArgs(p Plugin) Factory {
+	return func(args json.RawMessage) (Plugin, error) {
+		if len(args) > 0 && string(args) != "null" && string(args) != "{}" {
+			return nil, fmt.Errorf("takes no arguments")
+		}
+		return p, nil
+	}
+}
+
+type resourceFit struct{}
+
+func (resourceFit) Name() string { return "resource_fit" }
+
+// Filter checks t against every resource the node reports. A node that
+// could not fingerprint a resource (Total 0, e.g. memory and disk off
+// linux) is not rejected for it, since its capacity is unknown, not zero.
+func (resourceFit) Filter(t task.Task, n *Node) error {
+	if free := n.MemoryTotal - n.MemoryUsed - n.MemoryAllocated; n.MemoryTotal > 0 && free < t.Memory {
+		return fmt.Errorf("insufficient memory: %d bytes free, %d requested", max(free, 0), t.Memory)
+	}
+	if free := n.DiskTotal - n.DiskUsed - n.DiskAllocated; n.DiskTotal > 0 && free < t.Disk {
+		return fmt.Errorf("insufficient disk: %d bytes free, %d requested", max(free, 0), t.Disk)
+	}
+	if free := n.CpuTotal - n.CpuAllocated; n.CpuTotal > 0 && t.Cpu > 0 && free < t.Cpu {
+		return fmt.Errorf("insufficient cpu: %.2f cores free, %.2f requested", max(free, 0), t.Cpu)
+	}
+	return nil
+}
+
+type nodeSelector struct{}
+
+func (nodeSelector) Name() string { return "node_selector" }
+
+func (nodeSelector) Filter(t task.Task, n *Node) error {
+	for k, v := range t.NodeSelectors {
+		if nodeVal, ok := n.Tags[k]; !ok || nodeVal != v {
+			return fmt.Errorf("selector mismatch: %s=%s wanted, node has %q", k, v, nodeVal)
+		}
+	}
+	return nil
+}
+
+type hostPorts struct{}
+
+func (hostPorts) Name() string { return "host_ports" }
+
+func (hostPorts) Filter(t task.Task, n *Node) error {
+	var conflicts []string
+	for _, hostPort := range t.PortBindings {
+		if n.HostPorts[hostPort] {
+			conflicts = append(conflicts, hostPort)
+		}
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("port conflict: host port %s already bound", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// avoidNodes keeps a task off the nodes it was moved away from after
+// failing there repeatedly.
+type avoidNodes struct{}
+
+func (avoidNodes) Name() string { return "avoid_nodes" }
+
+func (avoidNodes) Filter(t task.Task, n *Node) error {
+	for _, id := range t.AvoidNodes {
+		if id == n.ID {
+			return fmt.Errorf("task failed on this node %d times in a row", t.RescheduleAfter)
+		}
+	}
+	return nil
+}
+
+type leastAllocated struct{}
+
+func (leastAllocated) Name() string { return "least_allocated" }
+
+// Score averages, over every resource the node reports, the fraction that
+// would still be free after placing t. Resources with no reported capacity
+// are left out rather than counted as full.
+func (leastAllocated) Score(t task.Task, n *Node) float64 {
+	var sum float64
+	var dims int
+
+	if n.MemoryTotal > 0 {
+		free := n.MemoryTotal - n.MemoryUsed - n.MemoryAllocated - t.Memory
+		sum += float64(free) / float64(n.MemoryTotal)
+		dims++
+	}
+	if n.CpuTotal > 0 {
+		free := n.CpuTotal - n.CpuAllocated - t.Cpu
+		sum += free / n.CpuTotal
+		dims++
+	}
+	if n.DiskTotal > 0 {
+		free := n.DiskTotal - n.DiskUsed - n.DiskAllocated - t.Disk
+		sum += float64(free) / float64(n.DiskTotal)
+		dims++
+	}
+
+	if dims == 0 {
+		return 0
+	}
+	return sum / float64(dims)
+}
+
+type spread struct{}
+
+func (spread) Name() string { return "spread" }
+
+func (spread) Score(t task.Task, n *Node) float64 {
+	return -float64(n.TaskCount)
+}
+
+type loadAverage struct{}
+
+func (loadAverage) Name() string { return "load" }
+
+func (loadAverage) Score(t task.Task, n *Node) float64 {
+	if n.CpuTotal <= 0 {
+		return -n.Load
+	}
+	return -n.Load / n.CpuTotal
+}
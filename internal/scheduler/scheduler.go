@@ -1,57 +1,157 @@
 package scheduler
 
 import (
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/bit2swaz/orion/internal/task"
 )
 
 type Node struct {
-	ID          string
-	MemoryTotal int64
-	MemoryUsed  int64
-	DiskTotal   int64
-	DiskUsed    int64
-	Tags        map[string]string
+	ID              string
+	MemoryTotal     int64
+	MemoryUsed      int64
+	MemoryAllocated int64
+	DiskTotal       int64
+	DiskUsed        int64
+	DiskAllocated   int64
+	CpuTotal        float64
+	CpuAllocated    float64
+	Load            float64
+	TaskCount       int
+	HostPorts       map[string]bool
+	Tags            map[string]string
 }
 
-type Scheduler struct{}
+func (n *Node) Reserve(t task.Task) {
+	n.MemoryAllocated += t.Memory
+	n.DiskAllocated += t.Disk
+	n.CpuAllocated += t.Cpu
+	n.TaskCount++
+
+	if len(t.PortBindings) > 0 && n.HostPorts == nil {
+		n.HostPorts = make(map[string]bool)
+	}
+	for _, hostPort := range t.PortBindings {
+		n.HostPorts[hostPort] = true
+	}
+}
+
+type Scheduler struct {
+	filters []FilterPlugin
+	scorers []weightedScorer
+}
 
 func New() *Scheduler {
-	return &Scheduler{}
+	s, err := NewWithConfig(DefaultConfig())
+	if err != nil {
+		panic(err)
+	}
+	return s
 }
 
-func (s *Scheduler) SelectCandidate(t task.Task, nodes []Node) *Node {
-	var bestNode *Node
-	var maxScore int64 = -1
+func NewWithConfig(cfg Config) (*Scheduler, error) {
+	filters, scorers, err := build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Scheduler{filters: filters, scorers: scorers}, nil
+}
 
-	for i, node := range nodes {
-		freeMemory := node.MemoryTotal - node.MemoryUsed
-		freeDisk := node.DiskTotal - node.DiskUsed
+func (s *Scheduler) SelectCandidate(t task.Task, nodes []Node) *Node {
+	node, _ := s.Schedule(t, nodes)
+	return node
+}
 
-		if freeDisk < t.Disk {
-			continue
-		}
-		if freeMemory < t.Memory {
+// Schedule picks the best node for t. When no node fits it returns nil and
+// the reason each node was rejected.
+func (s *Scheduler) Schedule(t task.Task, nodes []Node) (*Node, []task.NodeRejection) {
+	var feasible []int
+	var rejections []task.NodeRejection
+	for i := range nodes {
+		if plugin, err := s.filter(t, &nodes[i]); err != nil {
+			rejections = append(rejections, task.NodeRejection{
+				NodeID: nodes[i].ID,
+				Plugin: plugin,
+				Reason: err.Error(),
+			})
 			continue
 		}
+		feasible = append(feasible, i)
+	}
+	if len(feasible) == 0 {
+		sort.Slice(rejections, func(i, j int) bool {
+			return rejections[i].NodeID < rejections[j].NodeID
+		})
+		return nil, rejections
+	}
 
-		matchesSelectors := true
-		for k, v := range t.NodeSelectors {
-			if nodeVal, ok := node.Tags[k]; !ok || nodeVal != v {
-				matchesSelectors = false
-				break
-			}
+	totals := make([]float64, len(feasible))
+	raw := make([]float64, len(feasible))
+	for _, ws := range s.scorers {
+		for j, i := range feasible {
+			raw[j] = ws.plugin.Score(t, &nodes[i])
 		}
-		if !matchesSelectors {
-			continue
+		for j, score := range normalize(raw) {
+			totals[j] += ws.weight * score
 		}
+	}
 
-		score := freeMemory
+	best := 0
+	for j := range feasible {
+		if totals[j] > totals[best] {
+			best = j
+		}
+	}
+	return &nodes[feasible[best]], nil
+}
 
-		if score > maxScore {
-			maxScore = score
-			bestNode = &nodes[i]
+func (s *Scheduler) filter(t task.Task, n *Node) (string, error) {
+	for _, f := range s.filters {
+		if err := f.Filter(t, n); err != nil {
+			return f.Name(), err
 		}
 	}
+	return "", nil
+}
 
-	return bestNode
+func Summarize(total int, rejections []task.NodeRejection) string {
+	if total == 0 {
+		return "no cluster members available"
+	}
+
+	counts := map[string]int{}
+	for _, r := range rejections {
+		counts[r.Plugin]++
+	}
+	plugins := make([]string, 0, len(counts))
+	for p := range counts {
+		plugins = append(plugins, p)
+	}
+	sort.Strings(plugins)
+
+	parts := make([]string, len(plugins))
+	for i, p := range plugins {
+		parts[i] = fmt.Sprintf("%d rejected by %s", counts[p], p)
+	}
+	return fmt.Sprintf("0/%d nodes available: %s", total, strings.Join(parts, ", "))
+}
+
+func normalize(raw []float64) []float64 {
+	lo, hi := raw[0], raw[0]
+	for _, v := range raw {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+
+	out := make([]float64, len(raw))
+	for i, v := range raw {
+		if hi == lo {
+			out[i] = MaxNodeScore
+		} else {
+			out[i] = (v - lo) / (hi - lo) * MaxNodeScore
+		}
+	}
+	return out
 }
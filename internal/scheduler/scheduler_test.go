@@ -1,6 +1,8 @@
 package scheduler
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/bit2swaz/orion/internal/task"
@@ -31,6 +33,14 @@ func TestSelectCandidate(t *testing.T) {
 			},
 			wantNode: "",
 		},
+		{
+			name: "Unknown Capacity Is Not Zero Capacity",
+			task: task.Task{Memory: 500, Disk: 500},
+			nodes: []Node{
+				{ID: "node-darwin", CpuTotal: 4},
+			},
+			wantNode: "node-darwin",
+		},
 		{
 			name: "Selector Mismatch",
 			task: task.Task{NodeSelectors: map[string]string{"gpu": "true"}},
@@ -39,6 +49,15 @@ func TestSelectCandidate(t *testing.T) {
 			},
 			wantNode: "",
 		},
+		{
+			name: "Avoids Nodes The Task Failed On",
+			task: task.Task{Memory: 100, AvoidNodes: []string{"node-big"}},
+			nodes: []Node{
+				{ID: "node-small", MemoryTotal: 1000, MemoryUsed: 800},
+				{ID: "node-big", MemoryTotal: 1000, MemoryUsed: 100},
+			},
+			wantNode: "node-small",
+		},
 		{
 			name: "Best Score (Bin Packing)",
 			task: task.Task{Memory: 100},
@@ -48,6 +67,41 @@ func TestSelectCandidate(t *testing.T) {
 			},
 			wantNode: "node-big",
 		},
+		{
+			name: "Reservations Count Against Capacity",
+			task: task.Task{Memory: 300},
+			nodes: []Node{
+				{ID: "node-reserved", MemoryTotal: 1000, MemoryUsed: 100, MemoryAllocated: 700},
+				{ID: "node-free", MemoryTotal: 1000, MemoryUsed: 500},
+			},
+			wantNode: "node-free",
+		},
+		{
+			name: "Resource Constraint (Not Enough CPU)",
+			task: task.Task{Cpu: 2},
+			nodes: []Node{
+				{ID: "node1", MemoryTotal: 1000, CpuTotal: 4, CpuAllocated: 3},
+			},
+			wantNode: "",
+		},
+		{
+			name: "CPU Saturated Node Loses Despite Free RAM",
+			task: task.Task{Memory: 100, Cpu: 1},
+			nodes: []Node{
+				{ID: "node-ram", MemoryTotal: 1000, CpuTotal: 8, CpuAllocated: 7},
+				{ID: "node-balanced", MemoryTotal: 1000, MemoryUsed: 300, CpuTotal: 8, CpuAllocated: 1},
+			},
+			wantNode: "node-balanced",
+		},
+		{
+			name: "Disk Pressure Counts In Score",
+			task: task.Task{Memory: 100},
+			nodes: []Node{
+				{ID: "node-full-disk", MemoryTotal: 1000, DiskTotal: 1000, DiskUsed: 950},
+				{ID: "node-ok", MemoryTotal: 1000, MemoryUsed: 200, DiskTotal: 1000, DiskUsed: 100},
+			},
+			wantNode: "node-ok",
+		},
 	}
 
 	for _, tt := range tests {
@@ -68,3 +122,168 @@ func TestSelectCandidate(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectCandidate_SamePassReservations(t *testing.T) {
+	sched := New()
+	nodes := []Node{
+		{ID: "node1", MemoryTotal: 1000},
+		{ID: "node2", MemoryTotal: 1000},
+	}
+
+	placed := map[string]int{}
+	for i := 0; i < 4; i++ {
+		tk := task.Task{Memory: 400}
+		got := sched.SelectCandidate(tk, nodes)
+		if got == nil {
+			t.Fatalf("placement %d: expected a node, got nil", i)
+		}
+		got.Reserve(tk)
+		placed[got.ID]++
+	}
+
+	if placed["node1"] != 2 || placed["node2"] != 2 {
+		t.Errorf("expected 2 tasks per node, got %v", placed)
+	}
+	if got := sched.SelectCandidate(task.Task{Memory: 400}, nodes); got != nil {
+		t.Errorf("expected cluster to be full, got %s", got.ID)
+	}
+}
+
+type rejectNode struct{ id string }
+
+func (p rejectNode) Name() string { return "reject_node" }
+
+func (p rejectNode) Filter(t task.Task, n *Node) error {
+	if n.ID == p.id {
+		return fmt.Errorf("node %s is cordoned", n.ID)
+	}
+	return nil
+}
+
+func TestScheduler_Plugins(t *testing.T) {
+	Register("reject_node", func(args json.RawMessage) (Plugin, error) {
+		var a struct{ Node string }
+		if err := json.Unmarshal(args, &a); err != nil {
+			return nil, err
+		}
+		return rejectNode{id: a.Node}, nil
+	})
+
+	nodes := func() []Node {
+		return []Node{
+			{ID: "node-busy", MemoryTotal: 1000, TaskCount: 5, HostPorts: map[string]bool{"8080": true}},
+			{ID: "node-fuller", MemoryTotal: 1000, MemoryUsed: 400, TaskCount: 1},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		config   string
+		task     task.Task
+		wantNode string
+		wantErr  bool
+	}{
+		{
+			name:     "Defaults Prefer Free Memory",
+			config:   `{}`,
+			wantNode: "node-busy",
+		},
+		{
+			name:     "Spread Outweighs Free Memory",
+			config:   `{"plugins":[{"name":"spread","weight":3}]}`,
+			wantNode: "node-fuller",
+		},
+		{
+			name:     "Custom Filter Plugin",
+			config:   `{"plugins":[{"name":"reject_node","args":{"node":"node-busy"}}]}`,
+			wantNode: "node-fuller",
+		},
+		{
+			name:     "Host Port Conflict",
+			config:   `{}`,
+			task:     task.Task{PortBindings: map[string]string{"80/tcp": "8080"}},
+			wantNode: "node-fuller",
+		},
+		{
+			name:     "Disabled Host Port Filter",
+			config:   `{"plugins":[{"name":"host_ports","disabled":true}]}`,
+			task:     task.Task{PortBindings: map[string]string{"80/tcp": "8080"}},
+			wantNode: "node-busy",
+		},
+		{
+			name:    "Unknown Plugin",
+			config:  `{"plugins":[{"name":"gpu_affinity"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "Negative Weight",
+			config:  `{"plugins":[{"name":"spread","weight":-1}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ParseConfig([]byte(tt.config))
+			if err != nil {
+				t.Fatalf("ParseConfig failed: %v", err)
+			}
+			sched, err := NewWithConfig(cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected config error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewWithConfig failed: %v", err)
+			}
+
+			got := sched.SelectCandidate(tt.task, nodes())
+			if got == nil || got.ID != tt.wantNode {
+				t.Errorf("expected %s, got %v", tt.wantNode, got)
+			}
+		})
+	}
+}
+
+func TestSchedule_RejectionReport(t *testing.T) {
+	sched := New()
+	nodes := []Node{
+		{ID: "node-b", MemoryTotal: 1000, MemoryUsed: 900},
+		{ID: "node-a", MemoryTotal: 4000, Tags: map[string]string{"gpu": "false"}},
+		{ID: "node-c", MemoryTotal: 4000, Tags: map[string]string{"gpu": "true"}, HostPorts: map[string]bool{"80": true}},
+	}
+	tk := task.Task{
+		Memory:        500,
+		NodeSelectors: map[string]string{"gpu": "true"},
+		PortBindings:  map[string]string{"80/tcp": "80"},
+	}
+
+	got, rejections := sched.Schedule(tk, nodes)
+	if got != nil {
+		t.Fatalf("expected nil, got %s", got.ID)
+	}
+
+	want := []task.NodeRejection{
+		{NodeID: "node-a", Plugin: "node_selector"},
+		{NodeID: "node-b", Plugin: "resource_fit"},
+		{NodeID: "node-c", Plugin: "host_ports"},
+	}
+	if len(rejections) != len(want) {
+		t.Fatalf("expected %d rejections, got %v", len(want), rejections)
+	}
+	for i, w := range want {
+		if rejections[i].NodeID != w.NodeID || rejections[i].Plugin != w.Plugin || rejections[i].Reason == "" {
+			t.Errorf("rejection %d: expected %s/%s, got %+v", i, w.NodeID, w.Plugin, rejections[i])
+		}
+	}
+
+	summary := Summarize(len(nodes), rejections)
+	if summary != "0/3 nodes available: 1 rejected by host_ports, 1 rejected by node_selector, 1 rejected by resource_fit" {
+		t.Errorf("unexpected summary: %s", summary)
+	}
+	if Summarize(0, nil) != "no cluster members available" {
+		t.Errorf("unexpected summary for empty cluster: %s", Summarize(0, nil))
+	}
+}
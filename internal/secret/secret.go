@@ -0,0 +1,99 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// KeySize is the length of the cluster key: AES-256.
+const KeySize = 32
+
+// MaxSize bounds a secret's value, since every secret lives in the Raft log
+// and in every snapshot.
+const MaxSize = 64 << 10
+
+var ErrDecrypt = errors.New("secret cannot be decrypted with this node's cluster key")
+
+// Secret is a named value kept in Raft. Data is sealed with the cluster key
+// and is only opened by the node starting a task that uses it.
+type Secret struct {
+	Name       string
+	Data       []byte
+	Version    int
+	CreateTime time.Time
+	UpdateTime time.Time
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: use letters, digits, '_', '.' and '-'", name)
+	}
+	return nil
+}
+
+// Key is the cluster key secrets are sealed with. Every node that serves
+// the API or runs tasks with secrets needs the same key; it never enters
+// Raft.
+type Key struct {
+	aead cipher.AEAD
+}
+
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("cluster key must be %d bytes, got %d", KeySize, len(raw))
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead}, nil
+}
+
+// LoadKey reads a base64 encoded cluster key from path.
+func LoadKey(path string) (*Key, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("cluster key in %s is not base64: %v", path, err)
+	}
+	return NewKey(raw)
+}
+
+// Seal encrypts the value of secret name. The name is authenticated too, so
+// a sealed value cannot be passed off as another secret.
+func (k *Key) Seal(name string, value []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, value, []byte(name)), nil
+}
+
+func (k *Key) Open(name string, data []byte) ([]byte, error) {
+	n := k.aead.NonceSize()
+	if len(data) < n {
+		return nil, ErrDecrypt
+	}
+	value, err := k.aead.Open(nil, data[:n], data[n:], []byte(name))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return value, nil
+}
@@ -0,0 +1,63 @@
+package secret
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKey_SealOpen(t *testing.T) {
+	raw := make([]byte, KeySize)
+	rand.Read(raw)
+	path := filepath.Join(t.TempDir(), "cluster.key")
+	os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(raw)+"\n"), 0600)
+
+	key, err := LoadKey(path)
+	if err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+
+	sealed, err := key.Seal("db-password", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("hunter2")) {
+		t.Fatal("Sealed data contains the plaintext")
+	}
+
+	value, err := key.Open("db-password", sealed)
+	if err != nil || string(value) != "hunter2" {
+		t.Fatalf("Open() = %q, %v", value, err)
+	}
+	if _, err := key.Open("api-token", sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected sealed value to be bound to its name, got %v", err)
+	}
+
+	other, _ := NewKey(bytes.Repeat([]byte{1}, KeySize))
+	if _, err := other.Open("db-password", sealed); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected a different key to fail, got %v", err)
+	}
+}
+
+func TestNewKey_Size(t *testing.T) {
+	if _, err := NewKey(make([]byte, 16)); err == nil {
+		t.Error("Expected a 16 byte key to be rejected")
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"db-password", "TLS_KEY", "app.token"} {
+		if err := ValidateName(name); err != nil {
+			t.Errorf("ValidateName(%q) failed: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "-x", "a/b", "with space"} {
+		if err := ValidateName(name); err == nil {
+			t.Errorf("ValidateName(%q) should fail", name)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+var validName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type Service struct {
+	Name       string
+	Replicas   int
+	Template   task.Task
+	Update     UpdateConfig
+	Status     UpdateStatus
+	Version    int
+	CreateTime time.Time
+	UpdateTime time.Time
+}
+
+func (s *Service) Validate() error {
+	if !validName.MatchString(s.Name) {
+		return fmt.Errorf("invalid service name %q: use lowercase letters, digits and dashes", s.Name)
+	}
+	if s.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative, got %d", s.Replicas)
+	}
+	if s.Template.Image == "" {
+		return fmt.Errorf("template image is required")
+	}
+	if err := s.Template.Validate(); err != nil {
+		return err
+	}
+	return s.Update.Validate()
+}
+
+func (s *Service) NewTask(now time.Time) task.Task {
+	t := s.Template
+	t.ResetRuntime()
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%s", s.Name, t.ID.String()[:8])
+	t.ServiceName = s.Name
+	t.ServiceVersion = s.Version
+	t.StartTime = now
+	return t
+}
+
+// Live reports whether t still counts towards its service's replicas,
+// including finished replicas their restart policy brings back.
+func Live(t *task.Task) bool {
+	if t.DesiredStop {
+		return false
+	}
+	switch t.State {
+	case task.Pending, task.Scheduled, task.Running, task.Lost:
+		return true
+	}
+	return t.WillRestart()
+}
+
+// MaxRevisions is how many template revisions are kept per service.
+const MaxRevisions = 20
+
+type Revision struct {
+	Version  int
+	Template task.Task
+	Time     time.Time
+	Cause    string
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/task"
+)
+
+type UpdateState string
+
+const (
+	UpdateCompleted UpdateState = "completed"
+	UpdateRolling   UpdateState = "updating"
+	UpdatePaused    UpdateState = "paused"
+	UpdateHalted    UpdateState = "halted"
+)
+
+const (
+	StrategyRolling   = "rolling"
+	StrategyCanary    = "canary"
+	StrategyBlueGreen = "blue-green"
+)
+
+// UpdateConfig controls how template changes reach the replicas.
+//
+// A rolling update is bounded by MaxSurge, how many replicas may run above
+// Replicas, and MaxUnavailable, how many may be below it. Leaving both at
+// zero means a surge of one. A canary update first runs Canaries new
+// replicas next to the old ones and continues as a rolling update once
+// promoted. Blue/green starts a full new set and retires the old one when
+// all of it is ready.
+type UpdateConfig struct {
+	Strategy       string
+	MaxSurge       int
+	MaxUnavailable int
+	Canaries       int
+}
+
+func (u UpdateConfig) Validate() error {
+	switch u.Strategy {
+	case "", StrategyRolling, StrategyCanary, StrategyBlueGreen:
+	default:
+		return fmt.Errorf("unknown update strategy %q", u.Strategy)
+	}
+	if u.MaxSurge < 0 || u.MaxUnavailable < 0 {
+		return fmt.Errorf("maxSurge and maxUnavailable must not be negative")
+	}
+	if u.Canaries < 0 {
+		return fmt.Errorf("canaries must not be negative")
+	}
+	return nil
+}
+
+func (u UpdateConfig) CanaryCount() int {
+	if u.Canaries == 0 {
+		return 1
+	}
+	return u.Canaries
+}
+
+func (u UpdateConfig) Limits() (surge, unavailable int) {
+	if u.MaxSurge == 0 && u.MaxUnavailable == 0 {
+		return 1, 0
+	}
+	return u.MaxSurge, u.MaxUnavailable
+}
+
+type UpdateStatus struct {
+	State          UpdateState
+	Message        string
+	Promoted       bool
+	StartTime      time.Time
+	CompletionTime time.Time
+}
+
+// Ready reports whether a replica counts as available during an update:
+// running, and healthy if it has a health check.
+func Ready(t *task.Task) bool {
+	return Live(t) && t.State == task.Running && t.IsHealthy()
+}
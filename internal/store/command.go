@@ -0,0 +1,558 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/secret"
+	"github.com/bit2swaz/orion/internal/service"
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+type CommandType string
+
+const (
+	CommandTaskEvent  CommandType = "task_event"
+	CommandTaskReport CommandType = "task_report"
+	CommandTaskStop   CommandType = "task_stop"
+	CommandTaskPurge  CommandType = "task_purge"
+	CommandConfigSet  CommandType = "config_set"
+
+	CommandTaskUnschedulable CommandType = "task_unschedulable"
+	CommandTaskLost          CommandType = "task_lost"
+
+	CommandServicePut    CommandType = "service_put"
+	CommandServiceDelete CommandType = "service_delete"
+	CommandServiceStatus CommandType = "service_status"
+	CommandTaskRestart   CommandType = "task_restart"
+
+	CommandSecretPut    CommandType = "secret_put"
+	CommandSecretDelete CommandType = "secret_delete"
+)
+
+// CommandVersion is the newest payload schema this binary understands.
+// Entries written before the envelope existed decode as version 0.
+const CommandVersion = 1
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+type Command struct {
+	Type    CommandType     `json:"type"`
+	Version int             `json:"version"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type TaskRef struct {
+	ID        uuid.UUID
+	Timestamp time.Time
+}
+
+type TaskLost struct {
+	ID        uuid.UUID
+	NodeID    string
+	Timestamp time.Time
+	Reason    string
+}
+
+type TaskUnschedulable struct {
+	ID     uuid.UUID
+	Report task.SchedulingReport
+}
+
+type ServicePut struct {
+	Service   service.Service
+	Timestamp time.Time
+	Create    bool
+	Cause     string
+}
+
+type ServiceRef struct {
+	Name string
+}
+
+// ServiceStatus updates the rollout status of a service. It only applies
+// while the service is still at Version.
+type ServiceStatus struct {
+	Name    string
+	Version int
+	Status  service.UpdateStatus
+}
+
+// SecretPut carries a secret value sealed by the API node; the plaintext
+// never enters the log.
+type SecretPut struct {
+	Name      string
+	Data      []byte
+	Timestamp time.Time
+}
+
+type SecretDelete struct {
+	Name string
+}
+
+type ConfigEntry struct {
+	Key   string
+	Value json.RawMessage
+}
+
+type commandHandler func(s *Store, cmd Command) error
+
+var commandHandlers = map[CommandType]commandHandler{
+	CommandTaskEvent:  applyTaskEvent,
+	CommandTaskReport: applyTaskReport,
+	CommandTaskStop:   applyTaskStop,
+	CommandTaskPurge:  applyTaskPurge,
+	CommandConfigSet:  applyConfigSet,
+
+	CommandTaskUnschedulable: applyTaskUnschedulable,
+	CommandTaskLost:          applyTaskLost,
+	CommandTaskRestart:       applyTaskRestart,
+
+	CommandServicePut:    applyServicePut,
+	CommandServiceDelete: applyServiceDelete,
+	CommandServiceStatus: applyServiceStatus,
+
+	CommandSecretPut:    applySecretPut,
+	CommandSecretDelete: applySecretDelete,
+}
+
+func NewCommand(typ CommandType, payload interface{}) ([]byte, error) {
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Command{
+		Type:    typ,
+		Version: CommandVersion,
+		Payload: p,
+	})
+}
+
+func decodeCommand(data []byte) (Command, error) {
+	var cmd Command
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return cmd, err
+	}
+	if cmd.Type == "" {
+		return Command{Type: CommandTaskEvent, Version: 0, Payload: data}, nil
+	}
+	return cmd, nil
+}
+
+func (s *Store) ApplyCommand(typ CommandType, payload interface{}) error {
+	data, err := NewCommand(typ, payload)
+	if err != nil {
+		return err
+	}
+
+	future := s.R.Apply(data, 10*time.Second)
+	if err := future.Error(); err != nil {
+		return err
+	}
+	if err, ok := future.Response().(error); ok {
+		return err
+	}
+	return nil
+}
+
+func applyTaskEvent(s *Store, cmd Command) error {
+	var event task.TaskEvent
+	if err := json.Unmarshal(cmd.Payload, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal task event: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.putTaskEvent(event)
+	return nil
+}
+
+// applyTaskReport re-checks a node's report against the task as it stands
+// now, so of two racing reports only the first valid one is applied.
+func applyTaskReport(s *Store, cmd Command) error {
+	var event task.TaskEvent
+	if err := json.Unmarshal(cmd.Payload, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal task report: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.db[event.Task.ID.String()]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	t, err := mergeReport(existing, event)
+	if err != nil || t == nil {
+		return err
+	}
+	event.Task = *t
+	s.putTaskEvent(event)
+	return nil
+}
+
+// putTaskEvent stores the task carried by event. s.mu must be held.
+func (s *Store) putTaskEvent(event task.TaskEvent) {
+	id := event.Task.ID.String()
+	existing, ok := s.db[id]
+
+	t := event.Task
+	if ok && (event.State == task.Completed || event.State == task.Failed) {
+		t = *existing
+		t.State = event.State
+		t.FinishTime = event.Timestamp
+		if event.Task.Drift != nil {
+			t.Drift = event.Task.Drift
+		}
+		if event.Task.Exit != nil {
+			t.Exit = event.Task.Exit
+		}
+		if event.Task.Health != nil {
+			t.Health = event.Task.Health
+		}
+	}
+
+	msg := ""
+	if ok {
+		t.DesiredStop = t.DesiredStop || existing.DesiredStop
+		t.History = existing.History
+		switch {
+		case t.Drift != nil && (existing.Drift == nil || !t.Drift.Time.Equal(existing.Drift.Time)):
+			msg = "drift: " + t.Drift.Message
+		case t.Exit != nil && (existing.Exit == nil || !t.Exit.Time.Equal(existing.Exit.Time)):
+			msg = t.Exit.Reason
+		case t.Health != nil && (existing.Health == nil || t.Health.Status != existing.Health.Status):
+			msg = fmt.Sprintf("%s: %s", t.Health.Status, t.Health.Message)
+		}
+	}
+	if !ok || existing.State != t.State || existing.NodeID != t.NodeID || msg != "" {
+		t.Record(event.Timestamp, msg)
+	}
+
+	s.db[id] = &t
+}
+
+func applyTaskStop(s *Store, cmd Command) error {
+	var ref TaskRef
+	if err := json.Unmarshal(cmd.Payload, &ref); err != nil {
+		return fmt.Errorf("failed to unmarshal task stop: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.db[ref.ID.String()]
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	t := *existing
+	t.DesiredStop = true
+	if t.State == task.Pending || t.State == task.Lost {
+		t.State = task.Completed
+		t.FinishTime = ref.Timestamp
+	}
+	t.Record(ref.Timestamp, "stop requested")
+	s.db[ref.ID.String()] = &t
+	return nil
+}
+
+func applyTaskLost(s *Store, cmd Command) error {
+	var lost TaskLost
+	if err := json.Unmarshal(cmd.Payload, &lost); err != nil {
+		return fmt.Errorf("failed to unmarshal task lost: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.db[lost.ID.String()]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if existing.NodeID != lost.NodeID || (existing.State != task.Scheduled && existing.State != task.Running) {
+		return nil
+	}
+
+	t := *existing
+	t.State = task.Lost
+	t.PrevNodeID = existing.NodeID
+	t.PrevContainerID = existing.ContainerID
+	t.ContainerID = ""
+	if t.DesiredStop {
+		t.State = task.Completed
+		t.FinishTime = lost.Timestamp
+	}
+	t.Record(lost.Timestamp, lost.Reason)
+	s.db[lost.ID.String()] = &t
+	return nil
+}
+
+// applyTaskRestart brings a finished task back according to its restart
+// policy: on the same node, or back to Pending away from it once it failed
+// there RescheduleAfter times in a row.
+func applyTaskRestart(s *Store, cmd Command) error {
+	var ref TaskRef
+	if err := json.Unmarshal(cmd.Payload, &ref); err != nil {
+		return fmt.Errorf("failed to unmarshal task restart: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.db[ref.ID.String()]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if !existing.WillRestart() {
+		return fmt.Errorf("%w: task %s is not due for a restart", ErrConflict, existing.ID)
+	}
+
+	t := *existing
+	t.RestartCount++
+	t.Health = nil
+	if t.State == task.Failed {
+		t.NodeFailures++
+	} else {
+		t.NodeFailures = 0
+	}
+	t.FinishTime = time.Time{}
+
+	msg := fmt.Sprintf("restart %d", t.RestartCount)
+	if t.RescheduleAfter > 0 && t.NodeFailures >= t.RescheduleAfter {
+		msg = fmt.Sprintf("%s, moving off node %s after %d failures", msg, t.NodeID, t.NodeFailures)
+		t.AvoidNodes = append(append([]string(nil), t.AvoidNodes...), t.NodeID)
+		if len(t.AvoidNodes) > task.MaxAvoidNodes {
+			t.AvoidNodes = t.AvoidNodes[len(t.AvoidNodes)-task.MaxAvoidNodes:]
+		}
+		t.PrevNodeID = t.NodeID
+		t.PrevContainerID = t.ContainerID
+		t.NodeID = ""
+		t.ContainerID = ""
+		t.NodeFailures = 0
+		t.State = task.Pending
+	} else {
+		t.State = task.Scheduled
+	}
+	t.Record(ref.Timestamp, msg)
+
+	s.db[ref.ID.String()] = &t
+	return nil
+}
+
+func applyTaskPurge(s *Store, cmd Command) error {
+	var ref TaskRef
+	if err := json.Unmarshal(cmd.Payload, &ref); err != nil {
+		return fmt.Errorf("failed to unmarshal task purge: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.db, ref.ID.String())
+	return nil
+}
+
+func applyTaskUnschedulable(s *Store, cmd Command) error {
+	var u TaskUnschedulable
+	if err := json.Unmarshal(cmd.Payload, &u); err != nil {
+		return fmt.Errorf("failed to unmarshal scheduling report: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.db[u.ID.String()]
+	if !ok {
+		return ErrTaskNotFound
+	}
+	if existing.State != task.Pending && existing.State != task.Lost {
+		return nil
+	}
+
+	t := *existing
+	t.Unschedulable = &u.Report
+	s.db[u.ID.String()] = &t
+	return nil
+}
+
+func applyServicePut(s *Store, cmd Command) error {
+	var put ServicePut
+	if err := json.Unmarshal(cmd.Payload, &put); err != nil {
+		return fmt.Errorf("failed to unmarshal service: %v", err)
+	}
+	if err := put.Service.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	svc := put.Service
+	existing, ok := s.services[svc.Name]
+	switch {
+	case ok && put.Create:
+		return fmt.Errorf("%w: service %s already exists", ErrConflict, svc.Name)
+	case !ok && !put.Create:
+		return ErrServiceNotFound
+	case ok:
+		svc.CreateTime = existing.CreateTime
+		svc.Version = existing.Version
+		svc.Status = existing.Status
+		if !reflect.DeepEqual(svc.Template, existing.Template) {
+			svc.Version++
+			svc.Status = service.UpdateStatus{State: service.UpdateRolling, StartTime: put.Timestamp}
+		}
+	default:
+		svc.CreateTime = put.Timestamp
+		svc.Version = 1
+		svc.Status = service.UpdateStatus{}
+		delete(s.revisions, svc.Name)
+	}
+	svc.UpdateTime = put.Timestamp
+
+	if !ok || svc.Version != existing.Version {
+		s.recordRevision(&svc, put)
+	}
+	s.services[svc.Name] = &svc
+	return nil
+}
+
+func (s *Store) recordRevision(svc *service.Service, put ServicePut) {
+	cause := put.Cause
+	if cause == "" && put.Create {
+		cause = "created"
+	} else if cause == "" {
+		cause = "updated"
+	}
+
+	history := s.revisions[svc.Name]
+	if len(history) >= service.MaxRevisions {
+		history = history[len(history)-service.MaxRevisions+1:]
+	}
+	revs := make([]service.Revision, len(history), len(history)+1)
+	copy(revs, history)
+	s.revisions[svc.Name] = append(revs, service.Revision{
+		Version:  svc.Version,
+		Template: svc.Template,
+		Time:     put.Timestamp,
+		Cause:    cause,
+	})
+}
+
+func applyServiceDelete(s *Store, cmd Command) error {
+	var ref ServiceRef
+	if err := json.Unmarshal(cmd.Payload, &ref); err != nil {
+		return fmt.Errorf("failed to unmarshal service delete: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.services[ref.Name]; !ok {
+		return ErrServiceNotFound
+	}
+	delete(s.services, ref.Name)
+	delete(s.revisions, ref.Name)
+	return nil
+}
+
+func applySecretPut(s *Store, cmd Command) error {
+	var put SecretPut
+	if err := json.Unmarshal(cmd.Payload, &put); err != nil {
+		return fmt.Errorf("failed to unmarshal secret: %v", err)
+	}
+	if err := secret.ValidateName(put.Name); err != nil {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sec := secret.Secret{Name: put.Name, Data: put.Data, Version: 1, CreateTime: put.Timestamp, UpdateTime: put.Timestamp}
+	if existing, ok := s.secrets[put.Name]; ok {
+		sec.Version = existing.Version + 1
+		sec.CreateTime = existing.CreateTime
+	}
+	s.secrets[put.Name] = &sec
+	return nil
+}
+
+func applySecretDelete(s *Store, cmd Command) error {
+	var del SecretDelete
+	if err := json.Unmarshal(cmd.Payload, &del); err != nil {
+		return fmt.Errorf("failed to unmarshal secret delete: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.secrets[del.Name]; !ok {
+		return ErrSecretNotFound
+	}
+	for _, svc := range s.services {
+		if usesSecret(&svc.Template, del.Name) {
+			return fmt.Errorf("%w: secret %s is used by service %s", ErrConflict, del.Name, svc.Name)
+		}
+	}
+	for _, t := range s.db {
+		if usesSecret(t, del.Name) && service.Live(t) {
+			return fmt.Errorf("%w: secret %s is used by task %s", ErrConflict, del.Name, t.ID)
+		}
+	}
+	delete(s.secrets, del.Name)
+	return nil
+}
+
+func usesSecret(t *task.Task, name string) bool {
+	for _, ref := range t.Secrets {
+		if ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func applyConfigSet(s *Store, cmd Command) error {
+	var entry ConfigEntry
+	if err := json.Unmarshal(cmd.Payload, &entry); err != nil {
+		return fmt.Errorf("failed to unmarshal config entry: %v", err)
+	}
+	if entry.Key == "" {
+		return fmt.Errorf("config entry has no key")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config[entry.Key] = entry.Value
+	return nil
+}
+
+func applyServiceStatus(s *Store, cmd Command) error {
+	var st ServiceStatus
+	if err := json.Unmarshal(cmd.Payload, &st); err != nil {
+		return fmt.Errorf("failed to unmarshal service status: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.services[st.Name]
+	if !ok {
+		return ErrServiceNotFound
+	}
+	if existing.Version != st.Version {
+		return fmt.Errorf("%w: service %s is at version %d, not %d", ErrConflict, st.Name, existing.Version, st.Version)
+	}
+
+	svc := *existing
+	svc.Status = st.Status
+	s.services[st.Name] = &svc
+	return nil
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -10,46 +11,63 @@ import (
 	"sync"
 	"time"
 
+	"github.com/bit2swaz/orion/internal/secret"
+	"github.com/bit2swaz/orion/internal/service"
 	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
 	"github.com/hashicorp/raft"
 	raftboltdb "github.com/hashicorp/raft-boltdb"
 )
 
+var (
+	ErrTaskNotFound     = errors.New("task not found")
+	ErrServiceNotFound  = errors.New("service not found")
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrSecretNotFound   = errors.New("secret not found")
+	ErrConflict         = errors.New("conflict")
+)
+
 type Store struct {
-	R  *raft.Raft
-	db map[string]*task.Task
-	mu sync.RWMutex
+	R         *raft.Raft
+	db        map[string]*task.Task
+	services  map[string]*service.Service
+	revisions map[string][]service.Revision
+	secrets   map[string]*secret.Secret
+	config    map[string]json.RawMessage
+	mu        sync.RWMutex
+
+	watchMu  sync.Mutex
+	watchers []*Watcher
 }
 
 func New() *Store {
 	return &Store{
-		db: make(map[string]*task.Task),
+		db:        make(map[string]*task.Task),
+		services:  make(map[string]*service.Service),
+		revisions: make(map[string][]service.Revision),
+		secrets:   make(map[string]*secret.Secret),
+		config:    make(map[string]json.RawMessage),
 	}
 }
 
 func (s *Store) Apply(l *raft.Log) interface{} {
-	var event task.TaskEvent
-	if err := json.Unmarshal(l.Data, &event); err != nil {
-		panic(fmt.Sprintf("failed to unmarshal command: %s", err.Error()))
+	cmd, err := decodeCommand(l.Data)
+	if err != nil {
+		return fmt.Errorf("failed to decode command at index %d: %v", l.Index, err)
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	switch event.State {
-	case task.Pending:
-		s.db[event.Task.ID.String()] = &event.Task
-	case task.Completed, task.Failed:
-		if t, ok := s.db[event.Task.ID.String()]; ok {
-			t.State = event.State
-			t.FinishTime = event.Timestamp
-		} else {
-			s.db[event.Task.ID.String()] = &event.Task
-		}
-	default:
-		s.db[event.Task.ID.String()] = &event.Task
+	if cmd.Version > CommandVersion {
+		return fmt.Errorf("%w: %s version %d is newer than supported version %d", ErrUnknownCommand, cmd.Type, cmd.Version, CommandVersion)
 	}
 
+	handler, ok := commandHandlers[cmd.Type]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, cmd.Type)
+	}
+	if err := handler(s, cmd); err != nil {
+		return err
+	}
+	s.publish(changedTask(cmd), false)
 	return nil
 }
 
@@ -61,30 +79,173 @@ func (s *Store) Snapshot() (raft.FSMSnapshot, error) {
 	for k, v := range s.db {
 		o[k] = v
 	}
-	return &fsmSnapshot{store: o}, nil
+	svcs := make(map[string]*service.Service)
+	for k, v := range s.services {
+		svcs[k] = v
+	}
+	revs := make(map[string][]service.Revision)
+	for k, v := range s.revisions {
+		revs[k] = v
+	}
+	secs := make(map[string]*secret.Secret)
+	for k, v := range s.secrets {
+		secs[k] = v
+	}
+	c := make(map[string]json.RawMessage)
+	for k, v := range s.config {
+		c[k] = v
+	}
+	return &fsmSnapshot{state: snapshotState{Tasks: o, Services: svcs, Revisions: revs, Secrets: secs, Config: c}}, nil
 }
 
 func (s *Store) Restore(rc io.ReadCloser) error {
-	o := make(map[string]*task.Task)
-	if err := json.NewDecoder(rc).Decode(&o); err != nil {
+	b, err := io.ReadAll(rc)
+	if err != nil {
 		return err
 	}
 
-	s.db = o
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return err
+	}
+
+	var state snapshotState
+	if _, ok := probe["tasks"]; ok {
+		err = json.Unmarshal(b, &state)
+	} else {
+		// snapshots taken before config entries existed are a bare task map
+		err = json.Unmarshal(b, &state.Tasks)
+	}
+	if err != nil {
+		return err
+	}
+	if state.Tasks == nil {
+		state.Tasks = make(map[string]*task.Task)
+	}
+	if state.Services == nil {
+		state.Services = make(map[string]*service.Service)
+	}
+	if state.Revisions == nil {
+		state.Revisions = make(map[string][]service.Revision)
+	}
+	if state.Secrets == nil {
+		state.Secrets = make(map[string]*secret.Secret)
+	}
+	if state.Config == nil {
+		state.Config = make(map[string]json.RawMessage)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.db = state.Tasks
+	s.services = state.Services
+	s.revisions = state.Revisions
+	s.secrets = state.Secrets
+	s.config = state.Config
+	s.publish(uuid.Nil, true)
 	return nil
 }
 
+func (s *Store) GetConfig(key string) (json.RawMessage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.config[key]
+	return v, ok
+}
+
+func (s *Store) SetConfig(key string, value json.RawMessage) error {
+	return s.ApplyCommand(CommandConfigSet, ConfigEntry{Key: key, Value: value})
+}
+
 func (s *Store) GetTask(id string) (*task.Task, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	t, ok := s.db[id]
 	if !ok {
-		return nil, fmt.Errorf("task not found")
+		return nil, ErrTaskNotFound
 	}
 	return t, nil
 }
 
+// ReportTaskEvent applies a state change reported by the node running the
+// task. The checks run here to turn stale reports away early and again in
+// the FSM, where they see the state every other event has already left.
+func (s *Store) ReportTaskEvent(event task.TaskEvent) error {
+	current, err := s.GetTask(event.Task.ID.String())
+	if err != nil {
+		return err
+	}
+	if t, err := mergeReport(current, event); err != nil || t == nil {
+		return err
+	}
+
+	event.ID = current.ID
+	return s.ApplyCommand(CommandTaskReport, event)
+}
+
+// mergeReport folds a reported event into the current task. It returns nil
+// when the report carries nothing new.
+func mergeReport(current *task.Task, event task.TaskEvent) (*task.Task, error) {
+	if current.NodeID != event.Task.NodeID {
+		return nil, fmt.Errorf("%w: task %s is assigned to %q, not %q", ErrConflict, current.ID, current.NodeID, event.Task.NodeID)
+	}
+	drift := event.Task.Drift
+	newDrift := drift != nil && (current.Drift == nil || drift.Time.After(current.Drift.Time))
+	health := event.Task.Health
+	newHealth := health != nil && (current.Health == nil || health.Time.After(current.Health.Time))
+	if current.State == event.State {
+		if !newDrift && !newHealth {
+			return nil, nil
+		}
+	} else if !task.ValidTransition(current.State, event.State) {
+		return nil, fmt.Errorf("%w: task %s cannot move from %v to %v", ErrConflict, current.ID, current.State, event.State)
+	}
+
+	t := *current
+	t.State = event.State
+	if t.State == task.Running {
+		// it runs on this node, so the ones it failed on before may
+		// well be fine again
+		t.AvoidNodes = nil
+	}
+	if event.Task.ContainerID != "" {
+		t.ContainerID = event.Task.ContainerID
+	}
+	if newDrift {
+		t.Drift = drift
+	}
+	if newHealth {
+		t.Health = health
+	}
+	if event.Task.Exit != nil {
+		t.Exit = event.Task.Exit
+	}
+	return &t, nil
+}
+
+func (s *Store) StopTask(id string) (*task.Task, error) {
+	t, err := s.GetTask(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ApplyCommand(CommandTaskStop, TaskRef{ID: t.ID, Timestamp: time.Now()}); err != nil {
+		return nil, err
+	}
+	return s.GetTask(id)
+}
+
+func (s *Store) PurgeTask(id string) error {
+	t, err := s.GetTask(id)
+	if err != nil {
+		return err
+	}
+	return s.ApplyCommand(CommandTaskPurge, TaskRef{ID: t.ID, Timestamp: time.Now()})
+}
+
 func (s *Store) ListTasks() ([]*task.Task, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -96,17 +257,193 @@ func (s *Store) ListTasks() ([]*task.Task, error) {
 	return tasks, nil
 }
 
+func (s *Store) GetService(name string) (*service.Service, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	svc, ok := s.services[name]
+	if !ok {
+		return nil, ErrServiceNotFound
+	}
+	return svc, nil
+}
+
+func (s *Store) ListServices() ([]*service.Service, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var services []*service.Service
+	for _, svc := range s.services {
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+func (s *Store) CreateService(svc service.Service) (*service.Service, error) {
+	if _, err := s.GetService(svc.Name); err == nil {
+		return nil, fmt.Errorf("%w: service %s already exists", ErrConflict, svc.Name)
+	}
+	if err := s.ApplyCommand(CommandServicePut, ServicePut{Service: svc, Timestamp: time.Now(), Create: true}); err != nil {
+		return nil, err
+	}
+	return s.GetService(svc.Name)
+}
+
+func (s *Store) UpdateService(svc service.Service) (*service.Service, error) {
+	if _, err := s.GetService(svc.Name); err != nil {
+		return nil, err
+	}
+	if err := s.ApplyCommand(CommandServicePut, ServicePut{Service: svc, Timestamp: time.Now()}); err != nil {
+		return nil, err
+	}
+	return s.GetService(svc.Name)
+}
+
+// ServiceHistory returns the stored template revisions of a service, oldest
+// first.
+func (s *Store) ServiceHistory(name string) ([]service.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.services[name]; !ok {
+		return nil, ErrServiceNotFound
+	}
+	return s.revisions[name], nil
+}
+
+// RollbackService re-applies the template of an earlier revision as a new
+// version. A zero version means the revision before the current one.
+func (s *Store) RollbackService(name string, version int) (*service.Service, error) {
+	return s.rollbackService(name, version, "")
+}
+
+// AbortServiceUpdate returns a service to the revision before the one being
+// rolled out.
+func (s *Store) AbortServiceUpdate(name string) (*service.Service, error) {
+	svc, err := s.GetService(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.rollbackService(name, 0, fmt.Sprintf("aborted update to version %d", svc.Version))
+}
+
+func (s *Store) rollbackService(name string, version int, cause string) (*service.Service, error) {
+	svc, err := s.GetService(name)
+	if err != nil {
+		return nil, err
+	}
+	history, _ := s.ServiceHistory(name)
+
+	var target *service.Revision
+	for i := len(history) - 1; i >= 0; i-- {
+		rev := history[i]
+		if (version == 0 && rev.Version < svc.Version) || rev.Version == version {
+			target = &rev
+			break
+		}
+	}
+	if target == nil {
+		if version == 0 {
+			return nil, fmt.Errorf("%w: service %s has no earlier revision", ErrConflict, name)
+		}
+		return nil, fmt.Errorf("%w: revision %d of service %s", ErrRevisionNotFound, version, name)
+	}
+	if target.Version == svc.Version {
+		return nil, fmt.Errorf("%w: service %s is already at version %d", ErrConflict, name, svc.Version)
+	}
+
+	if cause == "" {
+		cause = fmt.Sprintf("rollback to version %d", target.Version)
+	}
+
+	rolled := *svc
+	rolled.Template = target.Template
+	put := ServicePut{
+		Service:   rolled,
+		Timestamp: time.Now(),
+		Cause:     cause,
+	}
+	if err := s.ApplyCommand(CommandServicePut, put); err != nil {
+		return nil, err
+	}
+	return s.GetService(name)
+}
+
+func (s *Store) SetServiceStatus(name string, version int, status service.UpdateStatus) error {
+	return s.ApplyCommand(CommandServiceStatus, ServiceStatus{Name: name, Version: version, Status: status})
+}
+
+func (s *Store) DeleteService(name string) error {
+	if _, err := s.GetService(name); err != nil {
+		return err
+	}
+	return s.ApplyCommand(CommandServiceDelete, ServiceRef{Name: name})
+}
+
+// GetSecret returns a secret with its value still sealed.
+func (s *Store) GetSecret(name string) (*secret.Secret, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sec, ok := s.secrets[name]
+	if !ok {
+		return nil, ErrSecretNotFound
+	}
+	return sec, nil
+}
+
+func (s *Store) ListSecrets() ([]*secret.Secret, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var secrets []*secret.Secret
+	for _, sec := range s.secrets {
+		secrets = append(secrets, sec)
+	}
+	return secrets, nil
+}
+
+// PutSecret creates or replaces a secret. data must already be sealed with
+// the cluster key.
+func (s *Store) PutSecret(name string, data []byte) (*secret.Secret, error) {
+	if err := s.ApplyCommand(CommandSecretPut, SecretPut{Name: name, Data: data, Timestamp: time.Now()}); err != nil {
+		return nil, err
+	}
+	return s.GetSecret(name)
+}
+
+// DeleteSecret removes a secret no service or unfinished task uses.
+func (s *Store) DeleteSecret(name string) error {
+	if _, err := s.GetSecret(name); err != nil {
+		return err
+	}
+	return s.ApplyCommand(CommandSecretDelete, SecretDelete{Name: name})
+}
+
 func (s *Store) IsLeader() bool {
 	return s.R.State() == raft.Leader
 }
 
+func (s *Store) LeaderID() string {
+	_, id := s.R.LeaderWithID()
+	return string(id)
+}
+
+type snapshotState struct {
+	Tasks     map[string]*task.Task         `json:"tasks"`
+	Services  map[string]*service.Service   `json:"services"`
+	Revisions map[string][]service.Revision `json:"revisions"`
+	Secrets   map[string]*secret.Secret     `json:"secrets"`
+	Config    map[string]json.RawMessage    `json:"config"`
+}
+
 type fsmSnapshot struct {
-	store map[string]*task.Task
+	state snapshotState
 }
 
 func (f *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
 	err := func() error {
-		b, err := json.Marshal(f.store)
+		b, err := json.Marshal(f.state)
 		if err != nil {
 			return err
 		}
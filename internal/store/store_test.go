@@ -1,11 +1,16 @@
 package store
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/bit2swaz/orion/internal/service"
 	"github.com/bit2swaz/orion/internal/task"
 	"github.com/google/uuid"
 	"github.com/hashicorp/raft"
@@ -72,6 +77,481 @@ func TestFSM(t *testing.T) {
 	}
 }
 
+func TestFSM_CommandEnvelope(t *testing.T) {
+	s := New()
+	taskID := uuid.New()
+
+	data, err := NewCommand(CommandTaskEvent, task.TaskEvent{
+		ID:    taskID,
+		State: task.Pending,
+		Task:  task.Task{ID: taskID, Name: "enveloped", State: task.Pending},
+	})
+	if err != nil {
+		t.Fatalf("NewCommand failed: %v", err)
+	}
+	if resp := s.Apply(&raft.Log{Data: data}); resp != nil {
+		t.Fatalf("Apply returned %v", resp)
+	}
+	if _, err := s.GetTask(taskID.String()); err != nil {
+		t.Fatalf("Task not found in store: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "Unknown Type", data: []byte(`{"type":"node_drain","version":1,"payload":{}}`)},
+		{name: "Future Version", data: []byte(`{"type":"task_event","version":99,"payload":{}}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.Apply(&raft.Log{Data: tt.data})
+			err, ok := resp.(error)
+			if !ok || !errors.Is(err, ErrUnknownCommand) {
+				t.Errorf("expected ErrUnknownCommand, got %v", resp)
+			}
+		})
+	}
+
+	if resp := s.Apply(&raft.Log{Data: []byte("not json")}); resp == nil {
+		t.Errorf("expected error for malformed entry, got nil")
+	}
+}
+
+func TestFSM_StopAndPurge(t *testing.T) {
+	s := New()
+	running := task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Running, ContainerID: "abc"}
+
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: running.ID, State: task.Running, Task: running})
+	mustApply(t, s, CommandTaskStop, TaskRef{ID: running.ID, Timestamp: time.Now()})
+
+	stored, _ := s.GetTask(running.ID.String())
+	if !stored.DesiredStop || stored.State != task.Running {
+		t.Fatalf("Expected running task flagged for stop, got %+v", stored)
+	}
+
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: running.ID, State: task.Running, Task: running})
+	stored, _ = s.GetTask(running.ID.String())
+	if !stored.DesiredStop {
+		t.Errorf("Stale event cleared the stop request")
+	}
+
+	finished := time.Now()
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: running.ID, State: task.Completed, Timestamp: finished, Task: running})
+	stored, _ = s.GetTask(running.ID.String())
+	if stored.State != task.Completed || !stored.FinishTime.Equal(finished) {
+		t.Errorf("Expected Completed with finish time, got %+v", stored)
+	}
+
+	mustApply(t, s, CommandTaskPurge, TaskRef{ID: running.ID})
+	if _, err := s.GetTask(running.ID.String()); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected task to be purged, got %v", err)
+	}
+}
+
+func TestFSM_Unschedulable(t *testing.T) {
+	s := New()
+	pending := task.Task{ID: uuid.New(), State: task.Pending}
+
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: pending.ID, State: task.Pending, Task: pending})
+	mustApply(t, s, CommandTaskUnschedulable, TaskUnschedulable{ID: pending.ID, Report: task.SchedulingReport{
+		Message:    "0/1 nodes available: 1 rejected by resource_fit",
+		Rejections: []task.NodeRejection{{NodeID: "node-1", Plugin: "resource_fit", Reason: "insufficient memory"}},
+	}})
+
+	stored, _ := s.GetTask(pending.ID.String())
+	if stored.Unschedulable == nil || len(stored.Unschedulable.Rejections) != 1 {
+		t.Fatalf("Expected scheduling report on task, got %+v", stored.Unschedulable)
+	}
+
+	scheduled := *stored
+	scheduled.State = task.Scheduled
+	scheduled.NodeID = "node-2"
+	scheduled.Unschedulable = nil
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: pending.ID, State: task.Scheduled, Task: scheduled})
+	mustApply(t, s, CommandTaskUnschedulable, TaskUnschedulable{ID: pending.ID, Report: task.SchedulingReport{Message: "stale"}})
+
+	stored, _ = s.GetTask(pending.ID.String())
+	if stored.Unschedulable != nil {
+		t.Errorf("Stale report attached to scheduled task: %+v", stored.Unschedulable)
+	}
+}
+
+func TestFSM_LostTaskHistory(t *testing.T) {
+	s := New()
+	running := task.Task{ID: uuid.New(), NodeID: "node-1", State: task.Running, ContainerID: "abc"}
+
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: running.ID, State: task.Running, Task: running})
+	mustApply(t, s, CommandTaskLost, TaskLost{ID: running.ID, NodeID: "node-2", Reason: "wrong node"})
+	if stored, _ := s.GetTask(running.ID.String()); stored.State != task.Running {
+		t.Fatalf("Task lost for a node it does not run on")
+	}
+
+	mustApply(t, s, CommandTaskLost, TaskLost{ID: running.ID, NodeID: "node-1", Reason: "node node-1 unreachable"})
+	stored, _ := s.GetTask(running.ID.String())
+	if stored.State != task.Lost || stored.ContainerID != "" {
+		t.Fatalf("Expected Lost task without container, got %+v", stored)
+	}
+
+	rescheduled := *stored
+	rescheduled.NodeID = "node-3"
+	rescheduled.State = task.Scheduled
+	rescheduled.History = nil
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: running.ID, State: task.Scheduled, Task: rescheduled})
+
+	stored, _ = s.GetTask(running.ID.String())
+	if len(stored.History) != 3 {
+		t.Fatalf("Expected 3 history entries, got %+v", stored.History)
+	}
+	lost := stored.History[1]
+	if lost.State != task.Lost || lost.NodeID != "node-1" || lost.Message != "node node-1 unreachable" {
+		t.Errorf("Unexpected lost entry: %+v", lost)
+	}
+	if last := stored.History[2]; last.State != task.Scheduled || last.NodeID != "node-3" {
+		t.Errorf("Unexpected reschedule entry: %+v", last)
+	}
+}
+
+func TestFSM_DriftHistory(t *testing.T) {
+	s := New()
+	id := uuid.New()
+	running := task.Task{ID: id, NodeID: "node-1", State: task.Running, ContainerID: "c1"}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Running, Task: running})
+
+	restarted := running
+	restarted.ContainerID = "c2"
+	restarted.Drift = &task.DriftReport{Time: time.Now(), Message: "container missing, restarted"}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Running, Timestamp: time.Now(), Task: restarted})
+
+	got, _ := s.GetTask(id.String())
+	if got.ContainerID != "c2" || got.Drift == nil {
+		t.Fatalf("Expected restart to record the new container and drift, got %+v", got)
+	}
+	if last := got.History[len(got.History)-1]; last.Message != "drift: container missing, restarted" {
+		t.Errorf("Unexpected history entry %+v", last)
+	}
+
+	failed := running
+	failed.Drift = &task.DriftReport{Time: time.Now().Add(time.Second), Message: "container exited"}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Failed, Timestamp: time.Now(), Task: failed})
+
+	got, _ = s.GetTask(id.String())
+	if got.State != task.Failed || got.Drift.Message != "container exited" || got.ContainerID != "c2" {
+		t.Errorf("Expected failed task with drift and unchanged container, got %+v", got)
+	}
+	if n := len(got.History); n != 3 || got.History[n-1].Message != "drift: container exited" {
+		t.Errorf("Unexpected history %+v", got.History)
+	}
+}
+
+func TestFSM_ExitStatus(t *testing.T) {
+	s := New()
+	id := uuid.New()
+	running := task.Task{ID: id, NodeID: "node-1", State: task.Running, ContainerID: "c1"}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Running, Task: running})
+
+	exited := running
+	exitTime := time.Now()
+	exited.Exit = &task.ExitStatus{Time: exitTime, Code: 137, OOMKilled: true, Reason: "OOM killed"}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Failed, Timestamp: exitTime, Task: exited})
+
+	got, _ := s.GetTask(id.String())
+	if got.State != task.Failed || !got.FinishTime.Equal(exitTime) {
+		t.Errorf("Expected failed task finished at exit time, got %v at %v", got.State, got.FinishTime)
+	}
+	if got.Exit == nil || got.Exit.Code != 137 || !got.Exit.OOMKilled {
+		t.Errorf("Expected exit status to be recorded, got %+v", got.Exit)
+	}
+	if last := got.History[len(got.History)-1]; last.Message != "OOM killed" || last.State != task.Failed {
+		t.Errorf("Unexpected history entry %+v", last)
+	}
+}
+
+func TestFSM_HealthHistory(t *testing.T) {
+	s := New()
+	id := uuid.New()
+	running := task.Task{ID: id, NodeID: "node-1", State: task.Running, ContainerID: "c1"}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Running, Task: running})
+
+	healthy := running
+	healthy.Health = &task.HealthReport{Time: time.Now(), Status: task.Healthy, Message: "1 http check(s) passed"}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Running, Timestamp: time.Now(), Task: healthy})
+
+	got, _ := s.GetTask(id.String())
+	if got.Health == nil || got.Health.Status != task.Healthy {
+		t.Fatalf("Expected healthy task, got %+v", got.Health)
+	}
+	if last := got.History[len(got.History)-1]; last.Message != "healthy: 1 http check(s) passed" {
+		t.Errorf("Unexpected history entry %+v", last)
+	}
+
+	unhealthy := running
+	unhealthy.Health = &task.HealthReport{Time: time.Now().Add(time.Second), Status: task.Unhealthy, Message: "connection refused"}
+	applyCommand(s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Failed, Timestamp: time.Now(), Task: unhealthy})
+
+	got, _ = s.GetTask(id.String())
+	if got.State != task.Failed || got.Health.Status != task.Unhealthy {
+		t.Errorf("Expected failed unhealthy task, got %v with %+v", got.State, got.Health)
+	}
+	if last := got.History[len(got.History)-1]; last.Message != "unhealthy: connection refused" {
+		t.Errorf("Unexpected history entry %+v", last)
+	}
+}
+
+func TestFSM_TaskRestart(t *testing.T) {
+	s := New()
+	id := uuid.New()
+	fail := func() {
+		current, _ := s.GetTask(id.String())
+		failed := *current
+		applyCommand(s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Failed, Timestamp: time.Now(), Task: failed})
+	}
+
+	applyCommand(s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Running, Task: task.Task{
+		ID: id, NodeID: "node-1", State: task.Running, ContainerID: "c1",
+		RestartPolicy: task.RestartOnFailure, RescheduleAfter: 2,
+	}})
+
+	if resp := applyCommand(s, CommandTaskRestart, TaskRef{ID: id}); !errors.Is(resp.(error), ErrConflict) {
+		t.Errorf("Expected restart of a running task to conflict, got %v", resp)
+	}
+
+	fail()
+	if resp := applyCommand(s, CommandTaskRestart, TaskRef{ID: id, Timestamp: time.Now()}); resp != nil {
+		t.Fatalf("Restart returned %v", resp)
+	}
+	got, _ := s.GetTask(id.String())
+	if got.State != task.Scheduled || got.NodeID != "node-1" || got.RestartCount != 1 || got.NodeFailures != 1 {
+		t.Fatalf("Expected restart on the same node, got %+v", got)
+	}
+	if last := got.History[len(got.History)-1]; last.Message != "restart 1" {
+		t.Errorf("Unexpected history entry %+v", last)
+	}
+
+	fail()
+	applyCommand(s, CommandTaskRestart, TaskRef{ID: id, Timestamp: time.Now()})
+	got, _ = s.GetTask(id.String())
+	if got.State != task.Pending || got.NodeID != "" || got.ContainerID != "" || got.NodeFailures != 0 {
+		t.Fatalf("Expected task to be rescheduled after 2 failures, got %+v", got)
+	}
+	if len(got.AvoidNodes) != 1 || got.AvoidNodes[0] != "node-1" || got.RestartCount != 2 {
+		t.Errorf("Expected node-1 to be avoided, got %+v", got)
+	}
+	if got.PrevNodeID != "node-1" || got.PrevContainerID != "c1" {
+		t.Errorf("Expected the container left on node-1 to be recorded, got %q %q", got.PrevNodeID, got.PrevContainerID)
+	}
+}
+
+func TestFSM_TaskRestartAvoidNodes(t *testing.T) {
+	s := New()
+	id := uuid.New()
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Pending, Task: task.Task{
+		ID: id, State: task.Pending, RestartPolicy: task.RestartOnFailure, RescheduleAfter: 1,
+	}})
+
+	// a task that fails on every node it is moved to
+	nodes := []string{"node-1", "node-2", "node-3", "node-4", "node-5"}
+	for _, node := range nodes {
+		current, _ := s.GetTask(id.String())
+		scheduled := *current
+		scheduled.NodeID = node
+		scheduled.State = task.Scheduled
+		mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Scheduled, Timestamp: time.Now(), Task: scheduled})
+		mustApply(t, s, CommandTaskReport, task.TaskEvent{ID: id, State: task.Failed, Timestamp: time.Now(), Task: task.Task{ID: id, NodeID: node}})
+		mustApply(t, s, CommandTaskRestart, TaskRef{ID: id, Timestamp: time.Now()})
+	}
+	got, _ := s.GetTask(id.String())
+	if want := nodes[len(nodes)-task.MaxAvoidNodes:]; !reflect.DeepEqual(got.AvoidNodes, want) {
+		t.Fatalf("Expected only the last %d nodes to be avoided, got %v", task.MaxAvoidNodes, got.AvoidNodes)
+	}
+
+	scheduled := *got
+	scheduled.NodeID = "node-1"
+	scheduled.State = task.Scheduled
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Scheduled, Timestamp: time.Now(), Task: scheduled})
+	mustApply(t, s, CommandTaskReport, task.TaskEvent{ID: id, State: task.Running, Timestamp: time.Now(), Task: task.Task{ID: id, NodeID: "node-1", ContainerID: "c1"}})
+	if got, _ := s.GetTask(id.String()); got.AvoidNodes != nil {
+		t.Errorf("Expected a running task to stop avoiding nodes, got %v", got.AvoidNodes)
+	}
+}
+
+func TestStore_ReportTaskEventValidation(t *testing.T) {
+	s := New()
+	scheduled := task.Task{
+		ID:     uuid.New(),
+		Name:   "scheduled-task",
+		NodeID: "node-1",
+		State:  task.Scheduled,
+	}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: scheduled.ID, State: task.Scheduled, Task: scheduled})
+
+	tests := []struct {
+		name    string
+		event   task.TaskEvent
+		wantErr error
+	}{
+		{
+			name:    "Unknown Task",
+			event:   task.TaskEvent{State: task.Running, Task: task.Task{ID: uuid.New(), NodeID: "node-1"}},
+			wantErr: ErrTaskNotFound,
+		},
+		{
+			name:    "Wrong Node",
+			event:   task.TaskEvent{State: task.Running, Task: task.Task{ID: scheduled.ID, NodeID: "node-2"}},
+			wantErr: ErrConflict,
+		},
+		{
+			name:    "Invalid Transition",
+			event:   task.TaskEvent{State: task.Pending, Task: task.Task{ID: scheduled.ID, NodeID: "node-1"}},
+			wantErr: ErrConflict,
+		},
+		{
+			name:  "Same State Is A No-op",
+			event: task.TaskEvent{State: task.Scheduled, Task: task.Task{ID: scheduled.ID, NodeID: "node-1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ReportTaskEvent(tt.event)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFSM_TaskReportRechecksState(t *testing.T) {
+	s := New()
+	id := uuid.New()
+	scheduled := task.Task{ID: id, NodeID: "node-1", State: task.Scheduled}
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Scheduled, Task: scheduled})
+
+	// Both reports were checked against Scheduled before either was applied.
+	mustApply(t, s, CommandTaskReport, task.TaskEvent{ID: id, State: task.Completed, Timestamp: time.Now(), Task: task.Task{ID: id, NodeID: "node-1"}})
+	resp := applyCommand(s, CommandTaskReport, task.TaskEvent{ID: id, State: task.Running, Timestamp: time.Now(), Task: task.Task{ID: id, NodeID: "node-1", ContainerID: "abc"}})
+	if err, _ := resp.(error); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected stale report to conflict, got %v", resp)
+	}
+	if resp := applyCommand(s, CommandTaskReport, task.TaskEvent{ID: id, State: task.Completed, Task: task.Task{ID: id, NodeID: "node-2"}}); !errors.Is(resp.(error), ErrConflict) {
+		t.Fatalf("expected report from another node to conflict, got %v", resp)
+	}
+
+	got, _ := s.GetTask(id.String())
+	if got.State != task.Completed || got.ContainerID != "" {
+		t.Errorf("expected task to stay Completed without a container, got %v %q", got.State, got.ContainerID)
+	}
+}
+
+func TestFSM_RestoreSnapshotFormats(t *testing.T) {
+	taskID := uuid.New()
+
+	legacy, _ := json.Marshal(map[string]*task.Task{
+		taskID.String(): {ID: taskID, Name: "legacy", State: task.Running},
+	})
+	s := New()
+	if err := s.Restore(io.NopCloser(bytes.NewReader(legacy))); err != nil {
+		t.Fatalf("Restore of legacy snapshot failed: %v", err)
+	}
+	if restored, err := s.GetTask(taskID.String()); err != nil || restored.Name != "legacy" {
+		t.Fatalf("Legacy task not restored: %v", err)
+	}
+
+	mustApply(t, s, CommandConfigSet, ConfigEntry{Key: "scheduler", Value: json.RawMessage(`{"plugins":[]}`)})
+
+	snap, _ := s.Snapshot()
+	sink := new(mockSnapshotSink)
+	if err := snap.Persist(sink); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+
+	restored := New()
+	if err := restored.Restore(io.NopCloser(bytes.NewReader(sink.data))); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if _, err := restored.GetTask(taskID.String()); err != nil {
+		t.Errorf("Task missing after restore: %v", err)
+	}
+	if v, ok := restored.GetConfig("scheduler"); !ok || string(v) != `{"plugins":[]}` {
+		t.Errorf("Config entry not restored, got %s", v)
+	}
+}
+
+func TestFSM_ServiceRevisions(t *testing.T) {
+	s := New()
+
+	svc := service.Service{Name: "web", Replicas: 1, Template: task.Task{Image: "nginx:0"}}
+	mustApply(t, s, CommandServicePut, ServicePut{Service: svc, Create: true})
+	for i := 1; i <= service.MaxRevisions+5; i++ {
+		svc.Template.Image = fmt.Sprintf("nginx:%d", i)
+		mustApply(t, s, CommandServicePut, ServicePut{Service: svc})
+		svc.Replicas++
+		mustApply(t, s, CommandServicePut, ServicePut{Service: svc})
+	}
+
+	history, _ := s.ServiceHistory("web")
+	if len(history) != service.MaxRevisions {
+		t.Fatalf("Expected %d revisions, got %d", service.MaxRevisions, len(history))
+	}
+	if last := history[len(history)-1]; last.Version != service.MaxRevisions+6 || last.Template.Image != svc.Template.Image {
+		t.Errorf("Unexpected latest revision: %+v", last)
+	}
+
+	snap, _ := s.Snapshot()
+	sink := new(mockSnapshotSink)
+	if err := snap.Persist(sink); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	restored := New()
+	if err := restored.Restore(io.NopCloser(bytes.NewReader(sink.data))); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got, _ := restored.ServiceHistory("web"); len(got) != service.MaxRevisions {
+		t.Errorf("Expected revisions to survive a snapshot, got %d", len(got))
+	}
+
+	applyCommand(s, CommandServiceDelete, ServiceRef{Name: "web"})
+	mustApply(t, s, CommandServicePut, ServicePut{Service: svc, Create: true})
+	if history, _ := s.ServiceHistory("web"); len(history) != 1 || history[0].Cause != "created" {
+		t.Errorf("Expected recreated service to start a fresh history, got %+v", history)
+	}
+}
+
+func TestFSM_Secrets(t *testing.T) {
+	s := New()
+
+	created := time.Now()
+	applyCommand(s, CommandSecretPut, SecretPut{Name: "db-password", Data: []byte("sealed-1"), Timestamp: created})
+	applyCommand(s, CommandSecretPut, SecretPut{Name: "db-password", Data: []byte("sealed-2"), Timestamp: created.Add(time.Minute)})
+	sec, err := s.GetSecret("db-password")
+	if err != nil || sec.Version != 2 || string(sec.Data) != "sealed-2" || !sec.CreateTime.Equal(created) {
+		t.Fatalf("Unexpected secret after update: %+v, %v", sec, err)
+	}
+	if resp := applyCommand(s, CommandSecretPut, SecretPut{Name: "bad/name"}); !errors.Is(resp.(error), ErrConflict) {
+		t.Errorf("Expected invalid name to be rejected, got %v", resp)
+	}
+
+	refs := []task.SecretRef{{Name: "db-password", Env: "DB_PASSWORD"}}
+	applyCommand(s, CommandServicePut, ServicePut{Service: service.Service{Name: "db", Replicas: 1, Template: task.Task{Image: "postgres", Secrets: refs}}, Create: true})
+	if resp := applyCommand(s, CommandSecretDelete, SecretDelete{Name: "db-password"}); !errors.Is(resp.(error), ErrConflict) {
+		t.Fatalf("Expected delete of a secret used by a service to conflict, got %v", resp)
+	}
+	applyCommand(s, CommandServiceDelete, ServiceRef{Name: "db"})
+
+	id := uuid.New()
+	applyCommand(s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Running, Task: task.Task{ID: id, State: task.Running, Secrets: refs}})
+	if resp := applyCommand(s, CommandSecretDelete, SecretDelete{Name: "db-password"}); !errors.Is(resp.(error), ErrConflict) {
+		t.Fatalf("Expected delete of a secret used by a running task to conflict, got %v", resp)
+	}
+	applyCommand(s, CommandTaskEvent, task.TaskEvent{ID: id, State: task.Completed, Task: task.Task{ID: id, State: task.Completed, Secrets: refs}})
+	if resp := applyCommand(s, CommandSecretDelete, SecretDelete{Name: "db-password"}); resp != nil {
+		t.Fatalf("Expected delete once no task uses the secret, got %v", resp)
+	}
+	if _, err := s.GetSecret("db-password"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected secret to be gone, got %v", err)
+	}
+}
+
 func TestStore_Open(t *testing.T) {
 	tmpDir := t.TempDir()
 	s := New()
@@ -88,6 +568,23 @@ func TestStore_Open(t *testing.T) {
 	}
 }
 
+// applyCommand applies a command straight to the FSM and returns its
+// response.
+func applyCommand(s *Store, typ CommandType, payload interface{}) interface{} {
+	data, err := NewCommand(typ, payload)
+	if err != nil {
+		return err
+	}
+	return s.Apply(&raft.Log{Data: data})
+}
+
+func mustApply(t *testing.T, s *Store, typ CommandType, payload interface{}) {
+	t.Helper()
+	if resp := applyCommand(s, typ, payload); resp != nil {
+		t.Fatalf("Apply %s returned %v", typ, resp)
+	}
+}
+
 type mockSnapshotSink struct {
 	data []byte
 }
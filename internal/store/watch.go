@@ -0,0 +1,88 @@
+package store
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Watcher collects the tasks touched by applied commands. C receives a value
+// whenever changes are pending; Drain returns and clears them. Changes are
+// coalesced, so a slow reader never blocks the FSM and never misses one.
+type Watcher struct {
+	C <-chan struct{}
+
+	c     chan struct{}
+	mu    sync.Mutex
+	tasks map[uuid.UUID]bool
+	all   bool
+}
+
+// Drain returns the IDs of the tasks changed since the last call. all is
+// true when the whole state may have changed, e.g. after a snapshot restore.
+func (w *Watcher) Drain() (ids []uuid.UUID, all bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for id := range w.tasks {
+		ids = append(ids, id)
+	}
+	all = w.all
+	clear(w.tasks)
+	w.all = false
+	return ids, all
+}
+
+func (w *Watcher) notify(id uuid.UUID, all bool) {
+	w.mu.Lock()
+	if id != uuid.Nil {
+		w.tasks[id] = true
+	}
+	w.all = w.all || all
+	w.mu.Unlock()
+
+	select {
+	case w.c <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Store) Watch() *Watcher {
+	c := make(chan struct{}, 1)
+	w := &Watcher{C: c, c: c, tasks: make(map[uuid.UUID]bool)}
+
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	s.watchers = append(s.watchers, w)
+	return w
+}
+
+func (s *Store) Unwatch(w *Watcher) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	for i, existing := range s.watchers {
+		if existing == w {
+			s.watchers = append(s.watchers[:i:i], s.watchers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Store) publish(id uuid.UUID, all bool) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	for _, w := range s.watchers {
+		w.notify(id, all)
+	}
+}
+
+// changedTask returns the task a command payload refers to, if any. Every
+// task command carries the task's ID in an ID field.
+func changedTask(cmd Command) uuid.UUID {
+	var ref struct{ ID uuid.UUID }
+	json.Unmarshal(cmd.Payload, &ref)
+	return ref.ID
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+func TestWatcher(t *testing.T) {
+	s := New()
+	w := s.Watch()
+
+	first, second := uuid.New(), uuid.New()
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: first, State: task.Pending, Task: task.Task{ID: first, State: task.Pending}})
+	mustApply(t, s, CommandTaskEvent, task.TaskEvent{ID: second, State: task.Pending, Task: task.Task{ID: second, State: task.Pending}})
+	mustApply(t, s, CommandTaskStop, TaskRef{ID: first})
+
+	select {
+	case <-w.C:
+	default:
+		t.Fatalf("Expected watcher to be signalled")
+	}
+	ids, all := w.Drain()
+	if len(ids) != 2 || all {
+		t.Errorf("Expected 2 coalesced task changes, got %v (all=%v)", ids, all)
+	}
+	select {
+	case <-w.C:
+		t.Errorf("Expected a single pending signal")
+	default:
+	}
+
+	applyCommand(s, CommandTaskStop, TaskRef{ID: uuid.New()})
+	if ids, _ := w.Drain(); len(ids) != 0 {
+		t.Errorf("Expected failed commands not to notify, got %v", ids)
+	}
+
+	snap, _ := s.Snapshot()
+	sink := new(mockSnapshotSink)
+	snap.Persist(sink)
+	if err := s.Restore(io.NopCloser(bytes.NewReader(sink.data))); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if _, all := w.Drain(); !all {
+		t.Errorf("Expected restore to signal a full resync")
+	}
+
+	s.Unwatch(w)
+	mustApply(t, s, CommandTaskPurge, TaskRef{ID: second})
+	if ids, _ := w.Drain(); len(ids) != 0 {
+		t.Errorf("Expected no changes after Unwatch, got %v", ids)
+	}
+}
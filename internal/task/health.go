@@ -0,0 +1,98 @@
+package task
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	CheckHTTP = "http"
+	CheckTCP  = "tcp"
+	CheckExec = "exec"
+)
+
+const (
+	DefaultCheckInterval = 10 * time.Second
+	DefaultCheckTimeout  = 5 * time.Second
+)
+
+// HealthCheck probes a running container: an HTTP GET of Path on Port that
+// must answer 2xx/3xx, a TCP connect to Port, or Command exiting 0 inside
+// the container. A task turns healthy after HealthyThreshold passing probes
+// in a row and unhealthy after UnhealthyThreshold failing ones (both default
+// to 1). Replace fails an unhealthy task so its restart policy or service
+// replaces it.
+type HealthCheck struct {
+	Type               string
+	Path               string
+	Port               int
+	Command            []string
+	IntervalSeconds    int
+	TimeoutSeconds     int
+	HealthyThreshold   int
+	UnhealthyThreshold int
+	Replace            bool
+}
+
+func (c *HealthCheck) Validate() error {
+	switch c.Type {
+	case CheckHTTP, CheckTCP:
+		if c.Port <= 0 || c.Port > 65535 {
+			return fmt.Errorf("%s health check needs a port, got %d", c.Type, c.Port)
+		}
+	case CheckExec:
+		if len(c.Command) == 0 {
+			return fmt.Errorf("exec health check needs a command")
+		}
+	default:
+		return fmt.Errorf("unknown health check type %q: use http, tcp or exec", c.Type)
+	}
+	if c.IntervalSeconds < 0 || c.TimeoutSeconds < 0 || c.HealthyThreshold < 0 || c.UnhealthyThreshold < 0 {
+		return fmt.Errorf("health check interval, timeout and thresholds must not be negative")
+	}
+	return nil
+}
+
+// ValidateHealthCheck checks t's health check, if it has one.
+func (t *Task) ValidateHealthCheck() error {
+	if t.HealthCheck == nil {
+		return nil
+	}
+	return t.HealthCheck.Validate()
+}
+
+func (c *HealthCheck) Interval() time.Duration {
+	if c.IntervalSeconds == 0 {
+		return DefaultCheckInterval
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+func (c *HealthCheck) Timeout() time.Duration {
+	if c.TimeoutSeconds == 0 {
+		return DefaultCheckTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+func (c *HealthCheck) Thresholds() (healthy, unhealthy int) {
+	return max(c.HealthyThreshold, 1), max(c.UnhealthyThreshold, 1)
+}
+
+type HealthStatus string
+
+const (
+	Healthy   HealthStatus = "healthy"
+	Unhealthy HealthStatus = "unhealthy"
+)
+
+type HealthReport struct {
+	Time    time.Time
+	Status  HealthStatus
+	Message string
+}
+
+// IsHealthy reports whether t passed its health check, or has none.
+func (t *Task) IsHealthy() bool {
+	return t.HealthCheck == nil || (t.Health != nil && t.Health.Status == Healthy)
+}
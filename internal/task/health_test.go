@@ -0,0 +1,67 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthCheckValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		check   HealthCheck
+		wantErr bool
+	}{
+		{"HTTP", HealthCheck{Type: CheckHTTP, Path: "/healthz", Port: 8080}, false},
+		{"TCP", HealthCheck{Type: CheckTCP, Port: 5432}, false},
+		{"Exec", HealthCheck{Type: CheckExec, Command: []string{"pg_isready"}}, false},
+		{"HTTP Without Port", HealthCheck{Type: CheckHTTP, Path: "/"}, true},
+		{"Exec Without Command", HealthCheck{Type: CheckExec}, true},
+		{"Unknown Type", HealthCheck{Type: "grpc", Port: 9000}, true},
+		{"Negative Threshold", HealthCheck{Type: CheckTCP, Port: 80, UnhealthyThreshold: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.check.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthCheckDefaults(t *testing.T) {
+	c := HealthCheck{Type: CheckTCP, Port: 80}
+	if c.Interval() != DefaultCheckInterval || c.Timeout() != DefaultCheckTimeout {
+		t.Errorf("Expected default interval and timeout, got %v and %v", c.Interval(), c.Timeout())
+	}
+	if h, u := c.Thresholds(); h != 1 || u != 1 {
+		t.Errorf("Expected thresholds of 1, got %d and %d", h, u)
+	}
+
+	c = HealthCheck{Type: CheckTCP, Port: 80, IntervalSeconds: 2, HealthyThreshold: 2, UnhealthyThreshold: 3}
+	if h, u := c.Thresholds(); c.Interval() != 2*time.Second || h != 2 || u != 3 {
+		t.Errorf("Unexpected interval %v and thresholds %d/%d", c.Interval(), h, u)
+	}
+}
+
+func TestIsHealthy(t *testing.T) {
+	check := &HealthCheck{Type: CheckTCP, Port: 80}
+	tests := []struct {
+		name string
+		task Task
+		want bool
+	}{
+		{"No Check", Task{}, true},
+		{"Not Probed Yet", Task{HealthCheck: check}, false},
+		{"Healthy", Task{HealthCheck: check, Health: &HealthReport{Status: Healthy}}, true},
+		{"Unhealthy", Task{HealthCheck: check, Health: &HealthReport{Status: Unhealthy}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.task.IsHealthy(); got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package task
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+const (
+	RestartBackoffBase = time.Second
+	MaxRestartBackoff  = 5 * time.Minute
+)
+
+// MaxAvoidNodes is how many of the nodes a task was moved off it keeps
+// avoiding, so in a small cluster it is never left with nowhere to go.
+const MaxAvoidNodes = 3
+
+// restartPolicy normalises RestartPolicy, accepting Docker's names for
+// tasks submitted before Orion managed restarts itself.
+func (t *Task) restartPolicy() string {
+	switch t.RestartPolicy {
+	case "", "no", RestartNever:
+		return RestartNever
+	case "unless-stopped", RestartAlways:
+		return RestartAlways
+	}
+	return t.RestartPolicy
+}
+
+func (t *Task) ValidateRestart() error {
+	switch t.restartPolicy() {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unknown restart policy %q: use never, on-failure or always", t.RestartPolicy)
+	}
+	if t.MaxRestarts < 0 || t.RescheduleAfter < 0 {
+		return fmt.Errorf("maxRestarts and rescheduleAfter must not be negative")
+	}
+	return nil
+}
+
+// WillRestart reports whether t has finished but its restart policy brings
+// it back. A MaxRestarts of zero means no limit.
+func (t *Task) WillRestart() bool {
+	if t.DesiredStop || (t.MaxRestarts > 0 && t.RestartCount >= t.MaxRestarts) {
+		return false
+	}
+	switch t.State {
+	case Failed:
+		return t.restartPolicy() != RestartNever
+	case Completed:
+		return t.restartPolicy() == RestartAlways
+	}
+	return false
+}
+
+// RestartDelay is how long after finishing t is restarted, doubling with
+// every restart up to MaxRestartBackoff.
+func (t *Task) RestartDelay() time.Duration {
+	delay := RestartBackoffBase
+	for i := 0; i < t.RestartCount && delay < MaxRestartBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxRestartBackoff)
+}
@@ -0,0 +1,64 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWillRestart(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		want bool
+	}{
+		{"Never On Failure", Task{State: Failed}, false},
+		{"Docker No", Task{State: Failed, RestartPolicy: "no"}, false},
+		{"On Failure", Task{State: Failed, RestartPolicy: RestartOnFailure}, true},
+		{"On Failure After Success", Task{State: Completed, RestartPolicy: RestartOnFailure}, false},
+		{"Always After Success", Task{State: Completed, RestartPolicy: RestartAlways}, true},
+		{"Docker Unless Stopped", Task{State: Completed, RestartPolicy: "unless-stopped"}, true},
+		{"Still Running", Task{State: Running, RestartPolicy: RestartAlways}, false},
+		{"Stopped On Purpose", Task{State: Completed, RestartPolicy: RestartAlways, DesiredStop: true}, false},
+		{"Attempts Left", Task{State: Failed, RestartPolicy: RestartAlways, MaxRestarts: 3, RestartCount: 2}, true},
+		{"Attempts Used Up", Task{State: Failed, RestartPolicy: RestartAlways, MaxRestarts: 3, RestartCount: 3}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.task.WillRestart(); got != tt.want {
+				t.Errorf("WillRestart() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{4, 16 * time.Second},
+		{9, MaxRestartBackoff},
+		{1000, MaxRestartBackoff},
+	}
+	for _, tt := range tests {
+		tk := Task{RestartCount: tt.count}
+		if got := tk.RestartDelay(); got != tt.want {
+			t.Errorf("RestartDelay() after %d restarts = %v, want %v", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRestart(t *testing.T) {
+	if err := (&Task{RestartPolicy: "sometimes"}).ValidateRestart(); err == nil {
+		t.Errorf("Expected unknown policy to be rejected")
+	}
+	if err := (&Task{RestartPolicy: RestartAlways, MaxRestarts: -1}).ValidateRestart(); err == nil {
+		t.Errorf("Expected negative maxRestarts to be rejected")
+	}
+	if err := (&Task{RestartPolicy: RestartOnFailure, MaxRestarts: 5, RescheduleAfter: 2}).ValidateRestart(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
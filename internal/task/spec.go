@@ -0,0 +1,133 @@
+package task
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bit2swaz/orion/internal/secret"
+)
+
+const (
+	MountBind   = "bind"
+	MountVolume = "volume"
+)
+
+// ReservedLabelPrefix marks the container labels Orion sets itself.
+const ReservedLabelPrefix = "orion."
+
+// Mount is a host directory (bind) or a named Docker volume mounted into the
+// task's container at Target.
+type Mount struct {
+	Type     string
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// SecretRef injects a secret into the task's container, either as the
+// environment variable Env or as a read-only file at File.
+type SecretRef struct {
+	Name string
+	Env  string
+	File string
+}
+
+var (
+	envName    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	volumeName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	capability = regexp.MustCompile(`^(CAP_)?[A-Z_]+$`)
+)
+
+// ValidateSpec checks the container settings of t: environment, labels,
+// mounts, tmpfs, secrets and capabilities.
+func (t *Task) ValidateSpec() error {
+	for k := range t.Env {
+		if !envName.MatchString(k) {
+			return fmt.Errorf("invalid environment variable name %q", k)
+		}
+	}
+	for k := range t.Labels {
+		if k == "" || strings.HasPrefix(k, ReservedLabelPrefix) {
+			return fmt.Errorf("invalid label %q: labels must be non-empty and not start with %q", k, ReservedLabelPrefix)
+		}
+	}
+	if t.WorkingDir != "" && !path.IsAbs(t.WorkingDir) {
+		return fmt.Errorf("working dir %q must be an absolute path", t.WorkingDir)
+	}
+
+	targets := map[string]bool{}
+	for _, m := range t.Mounts {
+		switch m.Type {
+		case MountBind:
+			if !path.IsAbs(m.Source) {
+				return fmt.Errorf("bind mount source %q must be an absolute path", m.Source)
+			}
+		case MountVolume:
+			if !volumeName.MatchString(m.Source) {
+				return fmt.Errorf("invalid volume name %q", m.Source)
+			}
+		default:
+			return fmt.Errorf("unknown mount type %q: use bind or volume", m.Type)
+		}
+		if err := checkTarget(m.Target, targets); err != nil {
+			return err
+		}
+	}
+	for target := range t.Tmpfs {
+		if err := checkTarget(target, targets); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range t.Secrets {
+		if err := secret.ValidateName(ref.Name); err != nil {
+			return err
+		}
+		switch {
+		case (ref.Env == "") == (ref.File == ""):
+			return fmt.Errorf("secret %s needs exactly one of env or file", ref.Name)
+		case ref.Env != "":
+			if !envName.MatchString(ref.Env) {
+				return fmt.Errorf("invalid environment variable name %q for secret %s", ref.Env, ref.Name)
+			}
+			if _, ok := t.Env[ref.Env]; ok {
+				return fmt.Errorf("secret %s and env both set %s", ref.Name, ref.Env)
+			}
+		default:
+			if err := checkTarget(ref.File, targets); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range append(append([]string(nil), t.CapAdd...), t.CapDrop...) {
+		if c = strings.ToUpper(c); c != "ALL" && !capability.MatchString(c) {
+			return fmt.Errorf("invalid capability %q", c)
+		}
+	}
+	return nil
+}
+
+func checkTarget(target string, seen map[string]bool) error {
+	if !path.IsAbs(target) || path.Clean(target) == "/" {
+		return fmt.Errorf("mount target %q must be an absolute path other than /", target)
+	}
+	if seen[path.Clean(target)] {
+		return fmt.Errorf("more than one mount at %q", target)
+	}
+	seen[path.Clean(target)] = true
+	return nil
+}
+
+// Validate checks everything a user can set on a task.
+func (t *Task) Validate() error {
+	if err := t.ValidateRestart(); err != nil {
+		return err
+	}
+	if err := t.ValidateHealthCheck(); err != nil {
+		return err
+	}
+	return t.ValidateSpec()
+}
@@ -0,0 +1,53 @@
+package task
+
+import "testing"
+
+func TestValidateSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		task    Task
+		wantErr bool
+	}{
+		{"Empty", Task{}, false},
+		{"Full Spec", Task{
+			Env:        map[string]string{"PORT": "8080", "_DEBUG": "1"},
+			Labels:     map[string]string{"team": "web"},
+			WorkingDir: "/app",
+			Mounts: []Mount{
+				{Type: MountBind, Source: "/srv/config", Target: "/etc/app", ReadOnly: true},
+				{Type: MountVolume, Source: "app-data", Target: "/var/lib/app"},
+			},
+			Tmpfs:   map[string]string{"/tmp": "size=64m"},
+			CapAdd:  []string{"net_admin"},
+			CapDrop: []string{"ALL"},
+		}, false},
+		{"Bad Env Name", Task{Env: map[string]string{"MY-VAR": "x"}}, true},
+		{"Reserved Label", Task{Labels: map[string]string{ReservedLabelPrefix + "task.id": "x"}}, true},
+		{"Relative Working Dir", Task{WorkingDir: "app"}, true},
+		{"Relative Bind Source", Task{Mounts: []Mount{{Type: MountBind, Source: "data", Target: "/data"}}}, true},
+		{"Bad Volume Name", Task{Mounts: []Mount{{Type: MountVolume, Source: "/data", Target: "/data"}}}, true},
+		{"Unknown Mount Type", Task{Mounts: []Mount{{Type: "nfs", Source: "srv:/x", Target: "/data"}}}, true},
+		{"Root Target", Task{Mounts: []Mount{{Type: MountVolume, Source: "data", Target: "/"}}}, true},
+		{"Duplicate Target", Task{
+			Mounts: []Mount{{Type: MountVolume, Source: "data", Target: "/data"}},
+			Tmpfs:  map[string]string{"/data/": ""},
+		}, true},
+		{"Bad Capability", Task{CapAdd: []string{"net admin"}}, true},
+		{"Secrets", Task{Secrets: []SecretRef{{Name: "db-password", Env: "DB_PASSWORD"}, {Name: "tls.key", File: "/run/secrets/tls.key"}}}, false},
+		{"Secret Without Target", Task{Secrets: []SecretRef{{Name: "db-password"}}}, true},
+		{"Secret With Both Targets", Task{Secrets: []SecretRef{{Name: "db-password", Env: "DB", File: "/run/db"}}}, true},
+		{"Secret Shadows Env", Task{Env: map[string]string{"DB": "x"}, Secrets: []SecretRef{{Name: "db", Env: "DB"}}}, true},
+		{"Secret File On Mount", Task{
+			Mounts:  []Mount{{Type: MountVolume, Source: "data", Target: "/data"}},
+			Secrets: []SecretRef{{Name: "db", File: "/data"}},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.task.ValidateSpec(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package task
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/docker/go-connections/nat"
@@ -15,24 +17,187 @@ const (
 	Running
 	Completed
 	Failed
+	Lost
 )
 
+var stateNames = map[State]string{
+	Pending:   "pending",
+	Scheduled: "scheduled",
+	Running:   "running",
+	Completed: "completed",
+	Failed:    "failed",
+	Lost:      "lost",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+func ParseState(name string) (State, error) {
+	for s, n := range stateNames {
+		if strings.EqualFold(n, name) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown task state %q", name)
+}
+
+var transitions = map[State][]State{
+	Pending:   {Scheduled, Failed},
+	Scheduled: {Running, Completed, Failed},
+	Running:   {Completed, Failed},
+}
+
+func ValidTransition(from, to State) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
 type Task struct {
-	ID            uuid.UUID
-	Name          string
-	NodeID        string
-	State         State
-	Image         string
-	Command       []string
-	Memory        int64
-	Disk          int64
-	Cpu           float64
-	NodeSelectors map[string]string
-	ExposedPorts  nat.PortSet
-	PortBindings  map[string]string
-	RestartPolicy string
-	StartTime     time.Time
-	FinishTime    time.Time
+	ID              uuid.UUID
+	Name            string
+	NodeID          string
+	State           State
+	Image           string
+	Command         []string
+	Entrypoint      []string
+	Env             map[string]string
+	WorkingDir      string
+	User            string
+	Labels          map[string]string
+	Mounts          []Mount
+	Secrets         []SecretRef
+	Tmpfs           map[string]string
+	CapAdd          []string
+	CapDrop         []string
+	ReadOnlyRootfs  bool
+	Memory          int64
+	Disk            int64
+	Cpu             float64
+	NodeSelectors   map[string]string
+	ExposedPorts    nat.PortSet
+	PortBindings    map[string]string
+	RestartPolicy   string
+	MaxRestarts     int
+	RescheduleAfter int
+	HealthCheck     *HealthCheck
+	RestartCount    int
+	NodeFailures    int
+	AvoidNodes      []string
+	// PrevNodeID and PrevContainerID name where the task ran before it was
+	// moved, so that node can remove what it left behind.
+	PrevNodeID      string
+	PrevContainerID string
+	ServiceName     string
+	ServiceVersion  int
+	ContainerID     string
+	DesiredStop     bool
+	Unschedulable   *SchedulingReport
+	Drift           *DriftReport
+	Exit            *ExitStatus
+	Health          *HealthReport
+	History         []HistoryEntry
+	StartTime       time.Time
+	FinishTime      time.Time
+}
+
+const MaxHistory = 20
+
+type HistoryEntry struct {
+	Time    time.Time
+	State   State
+	NodeID  string
+	Message string
+}
+
+func (t *Task) Record(at time.Time, message string) {
+	history := append([]HistoryEntry(nil), t.History...)
+	history = append(history, HistoryEntry{
+		Time:    at,
+		State:   t.State,
+		NodeID:  t.NodeID,
+		Message: message,
+	})
+	if len(history) > MaxHistory {
+		history = history[len(history)-MaxHistory:]
+	}
+	t.History = history
+}
+
+// ResetRuntime clears everything the cluster tracks about a task, from its
+// ID to its placement, restarts and history, leaving only the spec a client
+// or service template supplied. The result is a Pending task without an ID.
+func (t *Task) ResetRuntime() {
+	t.ID = uuid.Nil
+	t.NodeID = ""
+	t.State = Pending
+	t.ContainerID = ""
+	t.DesiredStop = false
+	t.Unschedulable = nil
+	t.Drift = nil
+	t.Exit = nil
+	t.Health = nil
+	t.History = nil
+	t.RestartCount = 0
+	t.NodeFailures = 0
+	t.AvoidNodes = nil
+	t.PrevNodeID = ""
+	t.PrevContainerID = ""
+	t.ServiceName = ""
+	t.ServiceVersion = 0
+	t.StartTime = time.Time{}
+	t.FinishTime = time.Time{}
+}
+
+type NodeRejection struct {
+	NodeID string
+	Plugin string
+	Reason string
+}
+
+type SchedulingReport struct {
+	Time       time.Time
+	Message    string
+	Rejections []NodeRejection
+}
+
+// SameRejections reports whether both reports reject the same nodes for the
+// same plugins, ignoring the exact numbers in the reasons.
+func (r *SchedulingReport) SameRejections(o *SchedulingReport) bool {
+	if r == nil || o == nil {
+		return r == o
+	}
+	if len(r.Rejections) != len(o.Rejections) {
+		return false
+	}
+	for i := range r.Rejections {
+		if r.Rejections[i].NodeID != o.Rejections[i].NodeID || r.Rejections[i].Plugin != o.Rejections[i].Plugin {
+			return false
+		}
+	}
+	return true
+}
+
+// DriftReport describes the last time a node found a task's container out
+// of line with the task's state.
+type DriftReport struct {
+	Time    time.Time
+	Message string
+}
+
+// ExitStatus is how a task's container terminated.
+type ExitStatus struct {
+	Time      time.Time
+	Code      int
+	OOMKilled bool
+	Reason    string
 }
 
 type TaskEvent struct {
@@ -0,0 +1,78 @@
+package task
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// specFields are the Task fields a client or service template supplies;
+// every other field belongs to the cluster.
+var specFields = map[string]bool{
+	"Name": true, "Image": true, "Command": true, "Entrypoint": true,
+	"Env": true, "WorkingDir": true, "User": true, "Labels": true,
+	"Mounts": true, "Secrets": true, "Tmpfs": true, "CapAdd": true,
+	"CapDrop": true, "ReadOnlyRootfs": true, "Memory": true, "Disk": true,
+	"Cpu": true, "NodeSelectors": true, "ExposedPorts": true,
+	"PortBindings": true, "RestartPolicy": true, "MaxRestarts": true,
+	"RescheduleAfter": true, "HealthCheck": true,
+}
+
+// fill sets v and everything it contains to a non-zero value.
+func fill(v reflect.Value) {
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		v.Set(reflect.ValueOf(time.Unix(1700000000, 0)))
+		return
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("x")
+	case reflect.Int, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Float64:
+		v.SetFloat(1)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Array:
+		fill(v.Index(0))
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0))
+	case reflect.Map:
+		k := reflect.New(v.Type().Key()).Elem()
+		e := reflect.New(v.Type().Elem()).Elem()
+		fill(k)
+		fill(e)
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(k, e)
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				fill(v.Field(i))
+			}
+		}
+	}
+}
+
+func TestResetRuntime(t *testing.T) {
+	var full Task
+	fill(reflect.ValueOf(&full).Elem())
+
+	reset := full
+	reset.ResetRuntime()
+
+	got, want := reflect.ValueOf(reset), reflect.ValueOf(full)
+	for i := 0; i < got.NumField(); i++ {
+		name := got.Type().Field(i).Name
+		if specFields[name] {
+			if !reflect.DeepEqual(got.Field(i).Interface(), want.Field(i).Interface()) {
+				t.Errorf("Expected spec field %s to be kept", name)
+			}
+		} else if !got.Field(i).IsZero() {
+			t.Errorf("Expected runtime field %s to be cleared, got %v", name, got.Field(i).Interface())
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/google/uuid"
+)
+
+const (
+	eventsRetryMin = time.Second
+	eventsRetryMax = 30 * time.Second
+)
+
+// Exit describes an Orion container that stopped running.
+type Exit struct {
+	ContainerID string
+	TaskID      uuid.UUID
+	Code        int
+	OOMKilled   bool
+	Reason      string
+	Time        time.Time
+}
+
+// WatchExits sends an Exit for every Orion container that dies until ctx is
+// done. It reconnects to the Docker events stream when it breaks, resuming
+// from the last event seen so no exit is missed.
+func (w *Worker) WatchExits(ctx context.Context, exits chan<- Exit) {
+	since := time.Now()
+	retry := eventsRetryMin
+
+	for {
+		last, err := w.streamExits(ctx, since, exits)
+		if ctx.Err() != nil {
+			return
+		}
+		if !last.IsZero() {
+			// Since is inclusive; don't deliver the last exit twice
+			since = last.Add(time.Nanosecond)
+			retry = eventsRetryMin
+		}
+		log.Printf("Docker events stream closed: %v, reconnecting in %s", err, retry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+		retry = min(retry*2, eventsRetryMax)
+	}
+}
+
+func (w *Worker) streamExits(ctx context.Context, since time.Time, exits chan<- Exit) (time.Time, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	msgs, errs := w.Client.Events(ctx, types.EventsOptions{
+		Since: fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("event", string(events.ActionDie)),
+			filters.Arg("label", LabelTaskID),
+		),
+	})
+
+	var last time.Time
+	for {
+		select {
+		case err := <-errs:
+			return last, err
+		case msg := <-msgs:
+			last = time.Unix(0, msg.TimeNano)
+			exit, err := w.exit(ctx, msg)
+			if err != nil {
+				log.Printf("Error reading exit of container %s: %v", msg.Actor.ID, err)
+				continue
+			}
+			select {
+			case exits <- exit:
+			case <-ctx.Done():
+				return last, ctx.Err()
+			}
+		}
+	}
+}
+
+func (w *Worker) exit(ctx context.Context, msg events.Message) (Exit, error) {
+	taskID, err := uuid.Parse(msg.Actor.Attributes[LabelTaskID])
+	if err != nil {
+		return Exit{}, fmt.Errorf("invalid %s label: %v", LabelTaskID, err)
+	}
+	exit := Exit{
+		ContainerID: msg.Actor.ID,
+		TaskID:      taskID,
+		Time:        time.Unix(0, msg.TimeNano),
+	}
+	exit.Code, _ = strconv.Atoi(msg.Actor.Attributes["exitCode"])
+
+	// the event only carries the exit code; the rest needs an inspect, which
+	// fails if the container was removed in the meantime
+	info, err := w.Client.ContainerInspect(ctx, msg.Actor.ID)
+	if err == nil && info.State != nil {
+		exit.Code = info.State.ExitCode
+		exit.OOMKilled = info.State.OOMKilled
+		exit.Reason = info.State.Error
+	}
+
+	switch {
+	case exit.OOMKilled:
+		exit.Reason = "OOM killed"
+	case exit.Reason == "":
+		exit.Reason = fmt.Sprintf("exited with code %d", exit.Code)
+	}
+	return exit, nil
+}
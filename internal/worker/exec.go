@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+type ExecOptions struct {
+	Cmd    []string
+	Tty    bool
+	Stdin  bool
+	Height uint
+	Width  uint
+}
+
+// ExecSession is a command running inside a container. Conn carries stdin;
+// Reader yields the output, multiplexed with stdcopy unless it runs on a tty.
+type ExecSession struct {
+	ID string
+	types.HijackedResponse
+}
+
+type ExecInfo struct {
+	ID          string
+	ContainerID string
+	Running     bool
+	ExitCode    int
+}
+
+// Exec starts cmd in a container and attaches to its streams. The caller
+// must Close the session.
+func (w *Worker) Exec(ctx context.Context, containerID string, opts ExecOptions) (*ExecSession, error) {
+	config := types.ExecConfig{
+		Cmd:          opts.Cmd,
+		Tty:          opts.Tty,
+		AttachStdin:  opts.Stdin,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	var size *[2]uint
+	if opts.Height > 0 && opts.Width > 0 {
+		size = &[2]uint{opts.Height, opts.Width}
+		config.ConsoleSize = size
+	}
+
+	exec, err := w.Client.ContainerExecCreate(ctx, containerID, config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating exec: %v", err)
+	}
+	resp, err := w.Client.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{Tty: opts.Tty, ConsoleSize: size})
+	if err != nil {
+		return nil, fmt.Errorf("error attaching to exec: %v", err)
+	}
+	return &ExecSession{ID: exec.ID, HijackedResponse: resp}, nil
+}
+
+func (w *Worker) ResizeExec(ctx context.Context, execID string, height, width uint) error {
+	return w.Client.ContainerExecResize(ctx, execID, container.ResizeOptions{Height: height, Width: width})
+}
+
+func (w *Worker) InspectExec(ctx context.Context, execID string) (ExecInfo, error) {
+	info, err := w.Client.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return ExecInfo{}, err
+	}
+	return ExecInfo{ID: info.ExecID, ContainerID: info.ContainerID, Running: info.Running, ExitCode: info.ExitCode}, nil
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/docker/docker/api/types"
+)
+
+const execPollInterval = 100 * time.Millisecond
+
+// Probe runs check once against the container. A nil error means it passed.
+func (w *Worker) Probe(ctx context.Context, containerID string, check task.HealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout())
+	defer cancel()
+
+	if check.Type == task.CheckExec {
+		return w.probeExec(ctx, containerID, check.Command)
+	}
+
+	ip, err := w.containerIP(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	addr := net.JoinHostPort(ip, strconv.Itoa(check.Port))
+
+	switch check.Type {
+	case task.CheckHTTP:
+		return probeHTTP(ctx, fmt.Sprintf("http://%s%s", addr, check.Path))
+	case task.CheckTCP:
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	return fmt.Errorf("unknown health check type %q", check.Type)
+}
+
+func probeHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+func (w *Worker) probeExec(ctx context.Context, containerID string, cmd []string) error {
+	exec, err := w.Client.ContainerExecCreate(ctx, containerID, types.ExecConfig{Cmd: cmd})
+	if err != nil {
+		return fmt.Errorf("error creating exec: %v", err)
+	}
+	if err := w.Client.ContainerExecStart(ctx, exec.ID, types.ExecStartCheck{Detach: true}); err != nil {
+		return fmt.Errorf("error starting exec: %v", err)
+	}
+
+	for {
+		info, err := w.Client.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return fmt.Errorf("error inspecting exec: %v", err)
+		}
+		if !info.Running {
+			if info.ExitCode != 0 {
+				return fmt.Errorf("%v exited with code %d", cmd, info.ExitCode)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v did not finish: %v", cmd, ctx.Err())
+		case <-time.After(execPollInterval):
+		}
+	}
+}
+
+func (w *Worker) containerIP(ctx context.Context, containerID string) (string, error) {
+	info, err := w.Client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	if info.NetworkSettings != nil {
+		if ip := info.NetworkSettings.IPAddress; ip != "" {
+			return ip, nil
+		}
+		for _, endpoint := range info.NetworkSettings.Networks {
+			if endpoint != nil && endpoint.IPAddress != "" {
+				return endpoint.IPAddress, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("container %s has no IP address", containerID)
+}
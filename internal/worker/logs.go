@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"context"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+type LogOptions struct {
+	Follow     bool
+	Tail       string
+	Since      string
+	Timestamps bool
+}
+
+// Logs streams a container's stdout and stderr until the output ends or, when
+// following, ctx is cancelled.
+func (w *Worker) Logs(ctx context.Context, containerID string, opts LogOptions, stdout, stderr io.Writer) error {
+	rc, err := w.Client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Tail:       opts.Tail,
+		Since:      opts.Since,
+		Timestamps: opts.Timestamps,
+	})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, rc)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/google/uuid"
+)
+
+// injectSecrets turns t's secrets into environment entries and read-only
+// bind mounts. File secrets are written to SecretsDir/<task id>, readable
+// by the container's user but not by other users of the host.
+func (w *Worker) injectSecrets(t task.Task, values map[string][]byte) (env []string, mounts []mount.Mount, err error) {
+	dir := ""
+	for i, ref := range t.Secrets {
+		value, ok := values[ref.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("secret %s was not resolved", ref.Name)
+		}
+		if ref.Env != "" {
+			env = append(env, ref.Env+"="+string(value))
+			continue
+		}
+
+		if dir == "" {
+			if dir, err = w.secretDir(t.ID); err != nil {
+				return nil, nil, err
+			}
+		}
+		path := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(path, value, 0444); err != nil {
+			return nil, nil, fmt.Errorf("error writing secret %s: %v", ref.Name, err)
+		}
+		mounts = append(mounts, mount.Mount{Type: mount.TypeBind, Source: path, Target: ref.File, ReadOnly: true})
+	}
+	return env, mounts, nil
+}
+
+// secretDir returns an empty directory for the secret files of a task.
+func (w *Worker) secretDir(taskID uuid.UUID) (string, error) {
+	if w.SecretsDir == "" {
+		return "", fmt.Errorf("no secrets directory configured for file secrets")
+	}
+	root, err := filepath.Abs(w.SecretsDir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, taskID.String())
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	return dir, os.Mkdir(dir, 0700)
+}
+
+// RemoveSecrets deletes the secret files written for a task.
+func (w *Worker) RemoveSecrets(taskID uuid.UUID) error {
+	if w.SecretsDir == "" {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(w.SecretsDir, taskID.String()))
+}
+
+// PruneSecrets deletes the secret files of every task not in keep.
+func (w *Worker) PruneSecrets(keep map[uuid.UUID]bool) error {
+	if w.SecretsDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(w.SecretsDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		id, err := uuid.Parse(e.Name())
+		if err != nil || keep[id] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(w.SecretsDir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/google/uuid"
+)
+
+func TestInjectSecrets(t *testing.T) {
+	w := &Worker{SecretsDir: t.TempDir()}
+	tk := task.Task{ID: uuid.New(), Secrets: []task.SecretRef{
+		{Name: "db-password", Env: "DB_PASSWORD"},
+		{Name: "tls.key", File: "/run/secrets/tls.key"},
+	}}
+	values := map[string][]byte{"db-password": []byte("hunter2"), "tls.key": []byte("-----BEGIN KEY-----")}
+
+	env, mounts, err := w.injectSecrets(tk, values)
+	if err != nil {
+		t.Fatalf("injectSecrets failed: %v", err)
+	}
+	if !reflect.DeepEqual(env, []string{"DB_PASSWORD=hunter2"}) {
+		t.Errorf("Unexpected env %v", env)
+	}
+	if len(mounts) != 1 || mounts[0].Target != "/run/secrets/tls.key" || !mounts[0].ReadOnly {
+		t.Fatalf("Unexpected mounts %+v", mounts)
+	}
+	if b, err := os.ReadFile(mounts[0].Source); err != nil || string(b) != "-----BEGIN KEY-----" {
+		t.Errorf("Unexpected secret file: %q, %v", b, err)
+	}
+	if info, _ := os.Stat(filepath.Dir(mounts[0].Source)); info.Mode().Perm() != 0700 {
+		t.Errorf("Expected secret directory to be private, got %v", info.Mode().Perm())
+	}
+
+	if _, _, err := w.injectSecrets(tk, map[string][]byte{}); err == nil {
+		t.Error("Expected unresolved secrets to fail")
+	}
+
+	if err := w.PruneSecrets(map[uuid.UUID]bool{tk.ID: true}); err != nil {
+		t.Fatalf("PruneSecrets failed: %v", err)
+	}
+	if _, err := os.Stat(mounts[0].Source); err != nil {
+		t.Errorf("Expected secrets of a kept task to stay: %v", err)
+	}
+	w.PruneSecrets(nil)
+	if _, err := os.Stat(mounts[0].Source); !os.IsNotExist(err) {
+		t.Errorf("Expected pruned secret file to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"sort"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/go-connections/nat"
+)
+
+// containerConfig translates a task into the Docker container and host
+// configuration its container is created with.
+func containerConfig(t task.Task) (*container.Config, *container.HostConfig) {
+	pb := nat.PortMap{}
+	exposedPorts := map[nat.Port]struct{}{}
+
+	for k, v := range t.PortBindings {
+		newBinding := nat.PortBinding{
+			HostIP:   "0.0.0.0",
+			HostPort: v,
+		}
+
+		port := nat.Port(k)
+
+		pb[port] = []nat.PortBinding{newBinding}
+		exposedPorts[port] = struct{}{}
+	}
+
+	env := make([]string, 0, len(t.Env))
+	for k, v := range t.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+
+	labels := map[string]string{}
+	for k, v := range t.Labels {
+		labels[k] = v
+	}
+	labels[LabelTaskID] = t.ID.String()
+
+	cc := &container.Config{
+		Image:        t.Image,
+		ExposedPorts: exposedPorts,
+		Cmd:          t.Command,
+		Entrypoint:   t.Entrypoint,
+		Env:          env,
+		WorkingDir:   t.WorkingDir,
+		User:         t.User,
+		Labels:       labels,
+	}
+
+	var mounts []mount.Mount
+	for _, m := range t.Mounts {
+		typ := mount.TypeBind
+		if m.Type == task.MountVolume {
+			typ = mount.TypeVolume
+		}
+		mounts = append(mounts, mount.Mount{Type: typ, Source: m.Source, Target: m.Target, ReadOnly: m.ReadOnly})
+	}
+
+	hc := &container.HostConfig{
+		Resources: container.Resources{
+			Memory:   t.Memory,
+			NanoCPUs: int64(t.Cpu * 1000000000),
+		},
+		PortBindings:   pb,
+		Mounts:         mounts,
+		Tmpfs:          t.Tmpfs,
+		CapAdd:         t.CapAdd,
+		CapDrop:        t.CapDrop,
+		ReadonlyRootfs: t.ReadOnlyRootfs,
+	}
+	return cc, hc
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bit2swaz/orion/internal/task"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/google/uuid"
+)
+
+func TestContainerConfig(t *testing.T) {
+	tk := task.Task{
+		ID:             uuid.New(),
+		Image:          "nginx",
+		Command:        []string{"-g", "daemon off;"},
+		Entrypoint:     []string{"nginx"},
+		Env:            map[string]string{"B": "2", "A": "1"},
+		WorkingDir:     "/app",
+		User:           "1000:1000",
+		Labels:         map[string]string{"team": "web"},
+		Mounts:         []task.Mount{{Type: task.MountVolume, Source: "data", Target: "/data", ReadOnly: true}},
+		Tmpfs:          map[string]string{"/tmp": "size=64m"},
+		CapDrop:        []string{"ALL"},
+		ReadOnlyRootfs: true,
+		Memory:         64 << 20,
+		Cpu:            0.5,
+		PortBindings:   map[string]string{"80/tcp": "8080"},
+	}
+
+	cc, hc := containerConfig(tk)
+
+	if !reflect.DeepEqual(cc.Env, []string{"A=1", "B=2"}) {
+		t.Errorf("Unexpected env %v", cc.Env)
+	}
+	if cc.Entrypoint[0] != "nginx" || cc.WorkingDir != "/app" || cc.User != "1000:1000" {
+		t.Errorf("Unexpected entrypoint, working dir or user: %+v", cc)
+	}
+	if cc.Labels["team"] != "web" || cc.Labels[LabelTaskID] != tk.ID.String() {
+		t.Errorf("Expected user labels plus the task ID label, got %v", cc.Labels)
+	}
+	if _, ok := cc.ExposedPorts["80/tcp"]; !ok || hc.PortBindings["80/tcp"][0].HostPort != "8080" {
+		t.Errorf("Unexpected ports %v / %v", cc.ExposedPorts, hc.PortBindings)
+	}
+
+	want := []mount.Mount{{Type: mount.TypeVolume, Source: "data", Target: "/data", ReadOnly: true}}
+	if !reflect.DeepEqual(hc.Mounts, want) {
+		t.Errorf("Unexpected mounts %+v", hc.Mounts)
+	}
+	if hc.Tmpfs["/tmp"] != "size=64m" || !hc.ReadonlyRootfs || hc.CapDrop[0] != "ALL" {
+		t.Errorf("Unexpected host config %+v", hc)
+	}
+	if hc.Memory != 64<<20 || hc.NanoCPUs != 500000000 {
+		t.Errorf("Unexpected resources %+v", hc.Resources)
+	}
+}
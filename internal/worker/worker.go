@@ -5,81 +5,102 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"github.com/bit2swaz/orion/internal/task"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
-	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
 )
 
+// LabelTaskID is set on every container Orion creates to the ID of its task.
+const LabelTaskID = task.ReservedLabelPrefix + "task.id"
+
 type Worker struct {
 	Name      string
 	Queue     chan task.Task
 	Db        map[uuid.UUID]*task.Task
 	TaskCount int
 	Client    *client.Client
+
+	// SecretsDir holds the files of file secrets while their tasks run.
+	SecretsDir string
 }
 
-func (w *Worker) Run(ctx context.Context, t task.Task) (string, error) {
+// Run starts a container for t. secrets holds the decrypted value of every
+// secret t refers to.
+func (w *Worker) Run(ctx context.Context, t task.Task, secrets map[string][]byte) (string, error) {
 	reader, err := w.Client.ImagePull(ctx, t.Image, types.ImagePullOptions{})
 	if err != nil {
 		return "", err
 	}
 	io.Copy(os.Stdout, reader)
 
-	rp := container.RestartPolicy{
-		Name: container.RestartPolicyMode(t.RestartPolicy),
+	cc, hc := containerConfig(t)
+	env, mounts, err := w.injectSecrets(t, secrets)
+	if err != nil {
+		return "", err
 	}
+	cc.Env = append(cc.Env, env...)
+	hc.Mounts = append(hc.Mounts, mounts...)
 
-	r := container.Resources{
-		Memory:   t.Memory,
-		NanoCPUs: int64(t.Cpu * 1000000000),
+	resp, err := w.Client.ContainerCreate(ctx, cc, hc, nil, nil, t.Name)
+	if err != nil {
+		return "", fmt.Errorf("error creating container: %v", err)
 	}
 
-	pb := nat.PortMap{}
-	exposedPorts := map[nat.Port]struct{}{}
+	if err := w.Client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return "", fmt.Errorf("error starting container: %v", err)
+	}
 
-	for k, v := range t.PortBindings {
-		newBinding := nat.PortBinding{
-			HostIP:   "0.0.0.0",
-			HostPort: v,
-		}
+	return resp.ID, nil
+}
 
-		port := nat.Port(k)
+func (w *Worker) Stop(ctx context.Context, containerID string) error {
+	return w.Client.ContainerStop(ctx, containerID, container.StopOptions{})
+}
 
-		pb[port] = []nat.PortBinding{newBinding}
-		exposedPorts[port] = struct{}{}
-	}
+func (w *Worker) Remove(ctx context.Context, containerID string) error {
+	return w.Client.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true})
+}
 
-	cc := container.Config{
-		Image:        t.Image,
-		ExposedPorts: exposedPorts,
-		Cmd:          t.Command,
-	}
+type Container struct {
+	ID     string
+	Name   string
+	TaskID uuid.UUID
+	State  string
+}
 
-	hc := container.HostConfig{
-		RestartPolicy: rp,
-		Resources:     r,
-		PortBindings:  pb,
+// Present reports whether the container is up or on its way back up.
+func (c Container) Present() bool {
+	switch c.State {
+	case "running", "restarting", "paused":
+		return true
 	}
+	return false
+}
 
-	resp, err := w.Client.ContainerCreate(ctx, &cc, &hc, nil, nil, t.Name)
+// List returns every container on the node. TaskID is set for containers
+// Orion created.
+func (w *Worker) List(ctx context.Context) ([]Container, error) {
+	list, err := w.Client.ContainerList(ctx, container.ListOptions{All: true})
 	if err != nil {
-		return "", fmt.Errorf("error creating container: %v", err)
+		return nil, err
 	}
 
-	if err := w.Client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
-		return "", fmt.Errorf("error starting container: %v", err)
+	containers := make([]Container, 0, len(list))
+	for _, c := range list {
+		ct := Container{ID: c.ID, State: c.State}
+		if len(c.Names) > 0 {
+			ct.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		if id, err := uuid.Parse(c.Labels[LabelTaskID]); err == nil {
+			ct.TaskID = id
+		}
+		containers = append(containers, ct)
 	}
-
-	return resp.ID, nil
-}
-
-func (w *Worker) Stop(containerID string) error {
-	ctx := context.Background()
-	return w.Client.ContainerStop(ctx, containerID, container.StopOptions{})
+	return containers, nil
 }
 
 func (w *Worker) CollectStats() (map[string]interface{}, error) {
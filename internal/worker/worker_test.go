@@ -33,7 +33,7 @@ func TestWorker_Run(t *testing.T) {
 		Cpu:     0.5,
 	}
 
-	dockerID, err := w.Run(context.Background(), task)
+	dockerID, err := w.Run(context.Background(), task, nil)
 
 	if err != nil {
 		t.Fatalf("Run() failed: %v", err)
@@ -43,7 +43,7 @@ func TestWorker_Run(t *testing.T) {
 	}
 	t.Logf("Container started with ID: %s", dockerID)
 
-	err = w.Stop(dockerID)
+	err = w.Stop(context.Background(), dockerID)
 	if err != nil {
 		t.Errorf("Stop() failed: %v", err)
 	}